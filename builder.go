@@ -0,0 +1,152 @@
+package rope
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Builder builds a Rope from content written to it piece by piece, as
+// strings.Builder does a string.  The content is cut into full leaves as it
+// arrives, and Rope assembles them into a balanced tree, so building a
+// document this way is linear in its length.  The zero value is ready to use.
+type Builder struct {
+	leaves        []*node
+	pending       strings.Builder
+	pendingLength int
+}
+
+// Join returns a new Rope holding the content of the parts with sep between
+// each, as strings.Join does, with the options of the first part which is not
+// nil.  A nil part is taken as empty.  Large parts are joined in by sharing
+// their trees, as by Concat, and the rest are copied into full leaves with the
+// separators, so the result is balanced however many parts there are.
+func Join(parts []*Rope, sep string) *Rope {
+	var b Builder
+	var options *Options
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		if part == nil {
+			continue
+		}
+		if options == nil {
+			options = &part.options
+		}
+
+		if part.root.length < bulkLength {
+			part.root.walk(func(value string) bool {
+				b.WriteString(value)
+				return true
+			})
+			continue
+		}
+
+		// The tree is now reachable from the new Rope as well, so is marked
+		// as shared, as by Root
+		part.root.shared.Store(true)
+		b.writeNode(part.root)
+	}
+
+	r := b.Rope()
+	if options != nil {
+		r.options = *options
+	}
+	return r
+}
+
+// JoinStrings returns a new Rope holding the parts with sep between each, as
+// strings.Join does, built in one pass without joining them into a single
+// string first
+func JoinStrings(parts []string, sep string) *Rope {
+	var b Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(part)
+	}
+	return b.Rope()
+}
+
+// Rope returns a Rope holding the content written so far, and resets the
+// Builder so that it may be used to build another
+func (b *Builder) Rope() *Rope {
+	b.flush(true)
+	if len(b.leaves) == 0 {
+		b.leaves = appendLeaves(nil, b.leaves, "", 0, fillLength)
+	}
+	r := &Rope{root: merge(nil, b.leaves)}
+	r.balance()
+	*b = Builder{}
+	return r
+}
+
+// Write appends the bytes to the content.  It always returns len(p) and a
+// nil error.
+func (b *Builder) Write(p []byte) (int, error) {
+	return b.WriteString(string(p))
+}
+
+// WriteByte appends the byte to the content.  A rune may be written one byte
+// at a time.  It always returns nil.
+func (b *Builder) WriteByte(c byte) error {
+	b.pending.WriteByte(c)
+	b.pendingLength++
+	b.flush(false)
+	return nil
+}
+
+// WriteRune appends the UTF-8 encoding of the rune to the content, and
+// returns its length and a nil error
+func (b *Builder) WriteRune(ru rune) (int, error) {
+	n, _ := b.pending.WriteRune(ru)
+	b.pendingLength++
+	b.flush(false)
+	return n, nil
+}
+
+// WriteString appends the string to the content, and returns its length and
+// a nil error
+func (b *Builder) WriteString(s string) (int, error) {
+	b.pending.WriteString(s)
+	b.pendingLength += utf8.RuneCountInString(s)
+	b.flush(false)
+	return len(s), nil
+}
+
+// writeNode appends the subtree to the content after whatever is pending,
+// joining it in rather than copying it.  Any part of the subtree which is
+// reachable from elsewhere must already be marked as shared.
+func (b *Builder) writeNode(n *node) {
+	b.flush(true)
+	b.leaves = append(b.leaves, n)
+}
+
+// flush cuts the pending content into leaves once there is enough of it to
+// fill several, or when final is set.  Until then an incomplete rune at the
+// end, as written a byte at a time, is kept back for the bytes which follow.
+func (b *Builder) flush(final bool) {
+	if b.pendingLength < bulkLength && !(final && b.pending.Len() > 0) {
+		return
+	}
+
+	s := b.pending.String()
+	var rest string
+	if !final {
+		i := len(s)
+		for i > 0 && len(s)-i < utf8.UTFMax && !utf8.RuneStart(s[i-1]) {
+			i--
+		}
+		if i > 0 && !utf8.FullRuneInString(s[i-1:]) {
+			s, rest = s[:i-1], s[i-1:]
+		}
+	}
+
+	if s != "" {
+		b.leaves = appendLeaves(nil, b.leaves, s, utf8.RuneCountInString(s), fillLength)
+	}
+	b.pending = strings.Builder{}
+	b.pending.WriteString(rest)
+	b.pendingLength = utf8.RuneCountInString(rest)
+}
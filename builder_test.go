@@ -0,0 +1,99 @@
+package rope
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_Builder(t *testing.T) {
+	loopTest(t, "Builder", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+
+		var expected strings.Builder
+		var b Builder
+		for i, ru := range []rune(init) {
+			switch i % 4 {
+			case 0:
+				b.WriteRune(ru)
+			case 1:
+				// A rune written a byte at a time is not split between
+				// leaves
+				for _, c := range []byte(string(ru)) {
+					b.WriteByte(c)
+				}
+			case 2:
+				b.WriteString(string(ru) + "\n")
+				expected.WriteString(string(ru) + "\n")
+				continue
+			default:
+				fmt.Fprint(&b, string(ru))
+			}
+			expected.WriteRune(ru)
+		}
+
+		r := b.Rope()
+		if r.String() != expected.String() {
+			t.Fatal("Incorrect content")
+		}
+		if r.Length() != utf8.RuneCountInString(expected.String()) {
+			t.Fatalf("Incorrect length: expected %d, got %d", utf8.RuneCountInString(expected.String()), r.Length())
+		}
+		if err := r.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		assertLogarithmicDepth(t, r)
+
+		if r := b.Rope(); r.String() != "" {
+			t.Fatal("Builder was not reset")
+		}
+	})
+}
+
+func Test_Join(t *testing.T) {
+	loopTest(t, "Join", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		strs := make([]string, 1000)
+		parts := make([]*Rope, len(strs))
+		for i := range strs {
+			// Every hundredth part is large enough for its tree to be shared
+			size := i % 7
+			if i%100 == 0 {
+				size = stringSize.size * 10
+			}
+			strs[i] = charSet.generator(size)
+			parts[i] = CreateRopeWithOptions(strs[i], Options{MaxBytes: 1 << 30})
+		}
+		expected := strings.Join(strs, ", ")
+
+		for _, r := range []*Rope{Join(parts, ", "), JoinStrings(strs, ", ")} {
+			if r.String() != expected {
+				t.Fatal("Incorrect content")
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+		}
+
+		r := Join(parts, ", ")
+		if r.options != parts[0].options {
+			t.Fatal("Options of the first part were not kept")
+		}
+		r.Insert(0, "x")
+		parts[0].Append("y")
+		if r.String() != "x"+expected || parts[0].String() != strs[0]+"y" {
+			t.Fatal("Joined ropes are not independent")
+		}
+	})
+
+	if r := Join([]*Rope{nil, CreateRope("a"), nil}, "-"); r.String() != "-a-" {
+		t.Fatalf("Incorrect join with nil parts: %q", r.String())
+	}
+	if r := Join(nil, "-"); !r.IsEmpty() {
+		t.Fatal("Join of no parts is not empty")
+	}
+	if r := JoinStrings(nil, "-"); !r.IsEmpty() {
+		t.Fatal("JoinStrings of no parts is not empty")
+	}
+}
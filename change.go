@@ -0,0 +1,68 @@
+package rope
+
+// ChangeEvent describes one change made to the content of a Rope: the runes
+// between Start and End were replaced with Text.  The offsets are those of
+// the content as it was just before the change, and OldLength is its length
+// in runes, so that a listener can apply the event to its own copy of the
+// content or adjust positions it holds.
+type ChangeEvent struct {
+	Start     int
+	End       int
+	ByteStart int
+	ByteEnd   int
+	OldLength int
+	Text      string
+}
+
+// listener is a function registered with OnChange
+type listener struct {
+	fn func(ChangeEvent)
+}
+
+// OnChange registers a function to call after each successful change to the
+// content of the Rope, and returns a function which unregisters it.  An edit
+// which fails, or which changes nothing, such as inserting an empty string,
+// is not reported.
+//
+// The function is called synchronously, with the Rope already holding the
+// new content, and must not edit the Rope.  An edit which makes several
+// changes, such as ApplyEdits or InsertAtAll, reports each of them in the
+// order it is made, which is from the end of the Rope to the start, so that
+// the offsets of every event hold when the events are applied in turn.
+func (r *Rope) OnChange(fn func(ChangeEvent)) func() {
+	l := &listener{fn}
+	r.listeners = append(r.listeners, l)
+	r.observedBytes = r.root.byteLength
+	return func() {
+		for i, registered := range r.listeners {
+			if registered == l {
+				r.listeners = append(r.listeners[:i:i], r.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// notify reports a change, which replaced the runes between start and end
+// with inserted runes, to the listeners registered with OnChange
+func (r *Rope) notify(start, end, inserted int) {
+	if len(r.listeners) == 0 || (start == end && inserted == 0) {
+		return
+	}
+
+	text := r.root.substring(start, start+inserted)
+	byteStart := r.root.byteOffset(start)
+	event := ChangeEvent{
+		Start:     start,
+		End:       end,
+		ByteStart: byteStart,
+		ByteEnd:   byteStart + r.observedBytes - (r.root.byteLength - len(text)),
+		OldLength: r.root.length - inserted + end - start,
+		Text:      text,
+	}
+	r.observedBytes = r.root.byteLength
+
+	for _, l := range r.listeners {
+		l.fn(event)
+	}
+}
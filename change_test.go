@@ -0,0 +1,78 @@
+package rope
+
+import (
+	"testing"
+)
+
+func Test_OnChange(t *testing.T) {
+	loopTest(t, "OnChange", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+
+		// One copy follows the byte ranges of the events, the other the runes
+		bytes, runes := init, []rune(init)
+		events := 0
+		r.OnChange(func(e ChangeEvent) {
+			if e.OldLength != len(runes) {
+				t.Fatalf("Incorrect old length: expected %d, got %d", len(runes), e.OldLength)
+			}
+			if string(runes[:e.Start]) != bytes[:e.ByteStart] || string(runes[e.Start:e.End]) != bytes[e.ByteStart:e.ByteEnd] {
+				t.Fatalf("Byte range [%d, %d) does not match rune range [%d, %d)", e.ByteStart, e.ByteEnd, e.Start, e.End)
+			}
+			bytes = bytes[:e.ByteStart] + e.Text + bytes[e.ByteEnd:]
+			runes = append(append(runes[:e.Start:e.Start], []rune(e.Text)...), runes[e.End:]...)
+			events++
+		})
+
+		for i, edit := range []func() error{
+			func() error { return r.Insert(stringSize.size/2, charSet.generator(100)) },
+			func() error { return r.Remove(5, 20) },
+			func() error { return r.Alter(10, 12, "🐿") },
+			func() error { return r.Append(charSet.generator(10)) },
+			func() error { return r.Prepend("\n") },
+			func() error { return r.InsertAtAll([]int{30, 0, 7}, "xy") },
+			func() error { return r.ApplyEdits([]EditOp{{1, 3, ""}, {8, 8, "🐿"}, {20, 25, "z"}}) },
+			func() error { return r.Truncate(r.Length() - 10) },
+			func() error { r.SetContent(charSet.generator(stringSize.size)); return nil },
+		} {
+			if err := edit(); err != nil {
+				t.Fatalf("Edit %d failed: %v", i, err)
+			}
+			if bytes != r.String() || string(runes) != r.String() {
+				t.Fatalf("Events of edit %d do not reproduce the content", i)
+			}
+		}
+
+		r.Begin()
+		r.Remove(0, 10)
+		r.Rollback()
+		if bytes != r.String() || string(runes) != r.String() {
+			t.Fatal("Events of rollback do not reproduce the content")
+		}
+	})
+}
+
+func Test_OnChange_Unregister(t *testing.T) {
+	r := CreateRope("abc")
+	var first, second []string
+	stop := r.OnChange(func(e ChangeEvent) { first = append(first, e.Text) })
+	r.OnChange(func(e ChangeEvent) { second = append(second, e.Text) })
+
+	r.Insert(1, "🐿")
+	r.Insert(0, "")
+	if err := r.Remove(5, 9); err == nil {
+		t.Fatal("Expected error")
+	}
+	stop()
+	r.Insert(0, "x")
+
+	if len(first) != 1 || first[0] != "🐿" {
+		t.Fatalf("Incorrect events before unregistering: %q", first)
+	}
+	if len(second) != 2 || second[1] != "x" {
+		t.Fatalf("Incorrect events: %q", second)
+	}
+	if r.String() != "xa🐿bc" {
+		t.Fatalf("Incorrect content: %q", r.String())
+	}
+}
@@ -0,0 +1,64 @@
+package rope
+
+import (
+	"math/bits"
+)
+
+// gear holds a pseudo-random value for each byte, for the rolling hash used
+// by ContentDefinedChunks.  The values are fixed, so that boundaries are the
+// same across processes.
+var gear [256]uint64
+
+func init() {
+	// splitmix64, from a fixed seed
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range gear {
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// ContentDefinedChunks divides the bytes of the Rope into chunks averaging
+// about avgBytes, and returns the byte offset at which each chunk after the
+// first begins.  A boundary is placed where a rolling hash of the preceding
+// bytes meets a condition, so boundaries depend only on nearby content: an
+// edit moves the boundaries around it, and those beyond are shifted by the
+// size of the edit but otherwise unchanged.  Chunks are at least a quarter and
+// at most four times avgBytes long, except for the last.  A chunk boundary
+// may fall within a multi-byte rune.
+func (r *Rope) ContentDefinedChunks(avgBytes int) []int {
+	if avgBytes < 1 {
+		return nil
+	}
+
+	minBytes, maxBytes := avgBytes/4, avgBytes*4
+	shift := 64 - (bits.Len(uint(avgBytes)) - 1)
+
+	var boundaries []int
+	var hash uint64
+	offset, start := 0, 0
+	r.root.walk(func(value string) bool {
+		for i := 0; i < len(value); i++ {
+			hash = hash<<1 + gear[value[i]]
+			size := offset + i + 1 - start
+			if size < minBytes {
+				continue
+			}
+			if hash>>shift == 0 || size >= maxBytes {
+				start = offset + i + 1
+				boundaries = append(boundaries, start)
+				hash = 0
+			}
+		}
+		offset += len(value)
+		return true
+	})
+
+	if n := len(boundaries); n > 0 && boundaries[n-1] == r.root.byteLength {
+		boundaries = boundaries[:n-1]
+	}
+	return boundaries
+}
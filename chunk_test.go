@@ -0,0 +1,74 @@
+package rope
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ContentDefinedChunks(t *testing.T) {
+	init := generateASCIIString(200000)
+	r := CreateRope(init)
+
+	for _, avg := range []int{64, 256, 1024, 4096} {
+		boundaries := r.ContentDefinedChunks(avg)
+		if !reflect.DeepEqual(boundaries, CreateRope(init).ContentDefinedChunks(avg)) {
+			t.Fatalf("Boundaries for %d are not reproducible", avg)
+		}
+
+		previous := 0
+		for _, b := range boundaries {
+			if b-previous < avg/4 || b-previous > avg*4 {
+				t.Fatalf("Chunk of %d bytes outside bounds for %d", b-previous, avg)
+			}
+			previous = b
+		}
+		if previous >= len(init) {
+			t.Fatalf("Boundary at end of rope")
+		}
+
+		mean := len(init) / (len(boundaries) + 1)
+		if mean < avg/2 || mean > avg*2 {
+			t.Fatalf("Mean chunk size %d too far from %d", mean, avg)
+		}
+	}
+}
+
+func Test_ContentDefinedChunks_Edit(t *testing.T) {
+	init := generateASCIIString(200000)
+	before := CreateRope(init).ContentDefinedChunks(1024)
+
+	r := CreateRope(init)
+	position := len(init) / 2
+	inserted := "inserted text"
+	r.Insert(position, inserted)
+	after := r.ContentDefinedChunks(1024)
+
+	unchanged := map[int]bool{}
+	for _, b := range after {
+		unchanged[b] = true
+	}
+	moved := 0
+	for _, b := range before {
+		if b > position {
+			b += len(inserted)
+		}
+		if !unchanged[b] {
+			moved++
+		}
+	}
+	if moved > 3 {
+		t.Fatalf("Edit moved %d of %d boundaries", moved, len(before))
+	}
+}
+
+func Test_ContentDefinedChunks_Small(t *testing.T) {
+	if boundaries := CreateRope("").ContentDefinedChunks(64); boundaries != nil {
+		t.Fatalf("Expected no boundaries, got %v", boundaries)
+	}
+	if boundaries := CreateRope("abc").ContentDefinedChunks(64); boundaries != nil {
+		t.Fatalf("Expected no boundaries, got %v", boundaries)
+	}
+	if boundaries := CreateRope("abc").ContentDefinedChunks(0); boundaries != nil {
+		t.Fatalf("Expected no boundaries, got %v", boundaries)
+	}
+}
@@ -0,0 +1,122 @@
+package rope
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LineCursor moves through the lines of a Rope one at a time in either
+// direction, as a viewport renderer does.  It keeps the leaf holding the
+// start of its current line, so moving to an adjacent line within the same
+// leaf does not search the tree.  A LineCursor is positioned between lines:
+// Next returns the line after the position and Prev the line before it.
+// After the Rope is edited, the cursor stays at the same line number.
+type LineCursor struct {
+	r    *Rope
+	line int
+
+	// start is the rune offset of the start of line, and leaf caches the
+	// content of the leaf holding it, at the byte offset within the leaf, as
+	// of the given revision of the Rope
+	start    int
+	leaf     string
+	offset   int
+	revision uint64
+}
+
+// LineCursor returns a LineCursor positioned before the 0-based line, so
+// that Next returns that line.  A line beyond the end of the Rope positions
+// the cursor after the last line.
+func (r *Rope) LineCursor(line int) *LineCursor {
+	c := &LineCursor{r: r, line: line}
+	c.seek()
+	return c
+}
+
+// Line returns the 0-based number of the line which Next would return
+func (c *LineCursor) Line() int {
+	c.sync()
+	return c.line
+}
+
+// Next returns the line after the cursor, without its newline, and moves the
+// cursor past it.  It returns false when the cursor is after the last line.
+func (c *LineCursor) Next() (text string, ok bool) {
+	c.sync()
+	root := c.r.root
+	if c.line > root.newlines {
+		return "", false
+	}
+
+	if c.offset >= len(c.leaf) {
+		if c.start == root.length {
+			// The empty last line after a trailing newline
+			c.line++
+			c.start++
+			c.leaf, c.offset = "", 0
+			return "", true
+		}
+		n, position := root.locate(c.start)
+		c.leaf, c.offset = *n.value, n.findByteOffsets(position)
+	}
+
+	if i := strings.IndexByte(c.leaf[c.offset:], '\n'); i >= 0 {
+		text = c.leaf[c.offset : c.offset+i]
+		c.start += utf8.RuneCountInString(text) + 1
+		c.offset += i + 1
+	} else {
+		// The line continues into the following leaves
+		end := root.lineEnd(c.line)
+		text = root.substring(c.start, end)
+		c.start = end + 1
+		c.leaf, c.offset = "", 0
+	}
+	c.line++
+	return text, true
+}
+
+// Prev returns the line before the cursor, without its newline, and moves
+// the cursor before it.  It returns false when the cursor is before the first
+// line.
+func (c *LineCursor) Prev() (text string, ok bool) {
+	c.sync()
+	if c.line == 0 {
+		return "", false
+	}
+
+	c.line--
+	if c.offset > 0 {
+		// The byte before the offset is the newline ending the line before
+		if i := strings.LastIndexByte(c.leaf[:c.offset-1], '\n'); i >= 0 {
+			text = c.leaf[i+1 : c.offset-1]
+			c.start -= utf8.RuneCountInString(text) + 1
+			c.offset = i + 1
+			return text, true
+		}
+	}
+
+	c.seek()
+	return c.r.root.substring(c.start, c.r.root.lineEnd(c.line)), true
+}
+
+// seek finds the start of the current line from the tree, clamping the line
+// to those of the Rope, and empties the cached leaf
+func (c *LineCursor) seek() {
+	root := c.r.root
+	c.line = min(max(c.line, 0), root.newlines+1)
+	if c.line > root.newlines {
+		// Just past the end, as though after a final newline
+		c.start = root.length + 1
+	} else {
+		c.start = root.lineStart(c.line)
+	}
+	c.leaf, c.offset = "", 0
+	c.revision = c.r.revision.Load()
+}
+
+// sync seeks again if the Rope has been edited since the cursor last moved
+func (c *LineCursor) sync() {
+	if c.revision != c.r.revision.Load() {
+		c.seek()
+	}
+}
@@ -0,0 +1,109 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_LineCursor(t *testing.T) {
+	loopTest(t, "LineCursor", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 100)
+		for i := range lines {
+			lines[i] = charSet.generator(i * stringSize.size / 100 % 37)
+		}
+		lines[50] = charSet.generator(2 * stringSize.size)
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		c := r.LineCursor(0)
+		for i, expected := range lines {
+			if text, ok := c.Next(); !ok || text != expected {
+				t.Fatalf("Incorrect line %d going forward: expected %q, got %q", i, expected, text)
+			}
+		}
+		if _, ok := c.Next(); ok {
+			t.Fatal("Expected no line after the last")
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			if text, ok := c.Prev(); !ok || text != lines[i] {
+				t.Fatalf("Incorrect line %d going backward: expected %q, got %q", i, lines[i], text)
+			}
+		}
+		if _, ok := c.Prev(); ok {
+			t.Fatal("Expected no line before the first")
+		}
+
+		c = r.LineCursor(60)
+		c.Prev()
+		c.Prev()
+		if text, _ := c.Next(); text != lines[58] {
+			t.Fatalf("Incorrect line after changing direction: expected %q, got %q", lines[58], text)
+		}
+	})
+}
+
+func Test_LineCursor_Edges(t *testing.T) {
+	r := CreateRope("a\nb\n")
+	c := r.LineCursor(10)
+	if c.Line() != 3 {
+		t.Fatalf("Incorrect clamped line: got %d", c.Line())
+	}
+	for _, expected := range []string{"", "b", "a"} {
+		if text, ok := c.Prev(); !ok || text != expected {
+			t.Fatalf("Incorrect line: expected %q, got %q", expected, text)
+		}
+	}
+	for _, expected := range []string{"a", "b", ""} {
+		if text, ok := c.Next(); !ok || text != expected {
+			t.Fatalf("Incorrect line: expected %q, got %q", expected, text)
+		}
+	}
+	if _, ok := c.Next(); ok {
+		t.Fatal("Expected no line after the last")
+	}
+	if text, ok := c.Prev(); !ok || text != "" {
+		t.Fatalf("Incorrect last line: expected %q, got %q", "", text)
+	}
+
+	if text, ok := CreateRope("").LineCursor(0).Next(); !ok || text != "" {
+		t.Fatalf("Expected a single empty line, got %q, %t", text, ok)
+	}
+}
+
+func Test_LineCursor_Edited(t *testing.T) {
+	r := CreateRope("one\ntwo\nthree\nfour")
+	c := r.LineCursor(0)
+	c.Next()
+	c.Next()
+
+	r.Insert(0, "zero\n")
+	if text, _ := c.Next(); text != "two" {
+		t.Fatalf("Incorrect line after insert: expected %q, got %q", "two", text)
+	}
+	if text, _ := c.Prev(); text != "two" {
+		t.Fatalf("Incorrect line going back: expected %q, got %q", "two", text)
+	}
+
+	r.Remove(0, r.Length()-4)
+	if c.Line() != 1 {
+		t.Fatalf("Incorrect line after remove: got %d", c.Line())
+	}
+	if text, _ := c.Prev(); text != "four" {
+		t.Fatalf("Incorrect line after remove: expected %q, got %q", "four", text)
+	}
+}
+
+func Benchmark_LineCursor(b *testing.B) {
+	lines := make([]string, 10000)
+	for i := range lines {
+		lines[i] = generateASCIIString(40)
+	}
+	r := CreateRope(strings.Join(lines, "\n"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := r.LineCursor(5000)
+		for j := 0; j < 50; j++ {
+			c.Next()
+		}
+	}
+}
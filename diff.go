@@ -0,0 +1,308 @@
+package rope
+
+import (
+	"io"
+	"iter"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChangedRanges returns the byte ranges of the Rope which differ from old,
+// such as a previously saved copy of its content.  The common prefix and
+// suffix are trimmed first, and the lines between them are compared, so an
+// edit yields a range covering the lines it touched in the Rope.  Lines
+// which exist only in old yield an empty range where they were removed.  The
+// ranges are in order, and do not overlap.  If the content is unchanged, nil
+// is returned.
+func (r *Rope) ChangedRanges(old string) []Range {
+	length := r.root.byteLength
+
+	prefix := 0
+	r.root.walk(func(value string) bool {
+		n := min(len(value), len(old)-prefix)
+		i := 0
+		for i < n && value[i] == old[prefix+i] {
+			i++
+		}
+		prefix += i
+		return i == len(value)
+	})
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+
+	// The common suffix is found walking forward, as the run of matching
+	// bytes which follows the last mismatch.
+	suffix := 0
+	span := min(length, len(old)) - prefix
+	offset := 0
+	r.root.walk(func(value string) bool {
+		for i := 0; i < len(value); i++ {
+			if offset+i < length-span {
+				continue
+			}
+			if value[i] == old[len(old)-length+offset+i] {
+				suffix++
+			} else {
+				suffix = 0
+			}
+		}
+		offset += len(value)
+		return true
+	})
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+
+	if prefix+suffix == length && prefix+suffix == len(old) {
+		return nil
+	}
+
+	var middle strings.Builder
+	middle.Grow(length - suffix - prefix)
+	read := r.OpenReader()
+	read.Seek(int64(prefix), io.SeekStart)
+	io.CopyN(&middle, read, int64(length-suffix-prefix))
+
+	a := splitLines(old[prefix : len(old)-suffix])
+	b := splitLines(middle.String())
+	offsets := make([]int, len(b)+1)
+	for i, line := range b {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	var ranges []Range
+	x, y := 0, 0
+	for _, match := range matchLines(a, b) {
+		if match[0] > x || match[1] > y {
+			ranges = append(ranges, Range{prefix + offsets[y], prefix + offsets[match[1]]})
+		}
+		x, y = match[0]+1, match[1]+1
+	}
+	if len(a) > x || len(b) > y {
+		ranges = append(ranges, Range{prefix + offsets[y], prefix + offsets[len(b)]})
+	}
+	return ranges
+}
+
+// Diff returns a script of edits which turns a into b, such as to send as
+// the changes of a document or to reload one while preserving its Markers.
+// The edits are as ApplyEdits takes them: in order, without overlapping, and
+// with rune offsets in a, so that applying them to a gives the content of b.
+// The common prefix and suffix are found by walking the leaves of both Ropes
+// side by side, and only the text between them is read into strings, where
+// its lines are compared as by ChangedRanges.  If the content is the same,
+// nil is returned.
+func Diff(a, b *Rope) []EditOp {
+	prefix := commonPrefix(a.root, b.root)
+	limit := min(a.root.byteLength, b.root.byteLength) - prefix
+	suffix := min(commonSuffix(a.root, b.root), limit)
+	if prefix == a.root.byteLength && prefix == b.root.byteLength {
+		return nil
+	}
+
+	// The bytes before the prefix are the same in both, so a rune divided by
+	// its end begins at the same offset in each; likewise for the suffix.
+	start := a.root.runeOffset(prefix)
+	if a.root.byteOffset(start) != prefix {
+		start--
+	}
+	aEnd := a.root.runeOffset(a.root.byteLength - suffix)
+	bEnd := b.root.length - (a.root.length - aEnd)
+
+	x := splitLines(a.root.substring(start, aEnd))
+	y := splitLines(b.root.substring(start, bEnd))
+	offsets := make([]int, len(x)+1)
+	offsets[0] = start
+	for i, line := range x {
+		offsets[i+1] = offsets[i] + utf8.RuneCountInString(line)
+	}
+
+	var edits []EditOp
+	i, j := 0, 0
+	for _, match := range append(matchLines(x, y), [2]int{len(x), len(y)}) {
+		if match[0] > i || match[1] > j {
+			edits = append(edits, EditOp{offsets[i], offsets[match[0]], strings.Join(y[j:match[1]], "")})
+		}
+		i, j = match[0]+1, match[1]+1
+	}
+	return edits
+}
+
+// matchLines returns the indices of the pairs of equal lines in a shortest
+// edit script from a to b, in order, using the linear space variant of Myers'
+// algorithm: the middle snake of the script is found by searching from both
+// ends at once, and the lines either side of it are matched in turn.  The
+// search keeps only its furthest point on each diagonal, so memory is linear
+// in the number of lines however many of them differ.
+func matchLines(a, b []string) [][2]int {
+	size := 2*((len(a)+len(b)+1)/2) + 3
+	forward := make([]int, size)
+	backward := make([]int, size)
+
+	var matches [][2]int
+	var match func(aStart, aEnd, bStart, bEnd int)
+	match = func(aStart, aEnd, bStart, bEnd int) {
+		for aStart < aEnd && bStart < bEnd && a[aStart] == b[bStart] {
+			matches = append(matches, [2]int{aStart, bStart})
+			aStart++
+			bStart++
+		}
+		suffix := 0
+		for aStart < aEnd-suffix && bStart < bEnd-suffix && a[aEnd-suffix-1] == b[bEnd-suffix-1] {
+			suffix++
+		}
+		aEnd -= suffix
+		bEnd -= suffix
+
+		if aStart < aEnd && bStart < bEnd {
+			x, y, u, v := middleSnake(a[aStart:aEnd], b[bStart:bEnd], forward, backward)
+			match(aStart, aStart+x, bStart, bStart+y)
+			for i := x; i < u; i++ {
+				matches = append(matches, [2]int{aStart + i, bStart + y + i - x})
+			}
+			match(aStart+u, aEnd, bStart+v, bEnd)
+		}
+
+		for i := 0; i < suffix; i++ {
+			matches = append(matches, [2]int{aEnd + i, bEnd + i})
+		}
+	}
+	match(0, len(a), 0, len(b))
+	return matches
+}
+
+// middleSnake returns the start and end of the snake in the middle of a
+// shortest edit script from a to b, which neither begin nor end alike.  The
+// furthest point reached on each diagonal is kept in forward and backward,
+// which must have room for as many diagonals as half the script may reach.
+func middleSnake(a, b []string, forward, backward []int) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			// The backward search is on diagonal delta-k, and has made d-1 rounds
+			if c := delta - k; delta%2 != 0 && c >= -(d-1) && c <= d-1 && x+backward[offset+c] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		// The backward search counts from the ends of a and b
+		for c := -d; c <= d; c += 2 {
+			var x int
+			if c == -d || (c != d && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+c] = x
+
+			if k := delta - c; delta%2 == 0 && k >= -d && k <= d && x+forward[offset+k] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("no middle snake")
+}
+
+// splitLines divides s after each newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// commonPrefix returns the length in bytes of the longest common prefix of
+// the content of the nodes
+func commonPrefix(a, b *node) int {
+	next, stop := iter.Pull(func(yield func(string) bool) { b.walk(yield) })
+	defer stop()
+
+	prefix := 0
+	rest := ""
+	a.walk(func(value string) bool {
+		for value != "" {
+			if rest == "" {
+				var ok bool
+				if rest, ok = next(); !ok {
+					return false
+				}
+				continue
+			}
+
+			n := min(len(value), len(rest))
+			i := 0
+			for i < n && value[i] == rest[i] {
+				i++
+			}
+			prefix += i
+			if i < n {
+				return false
+			}
+			value, rest = value[n:], rest[n:]
+		}
+		return true
+	})
+	return prefix
+}
+
+// commonSuffix returns the length in bytes of the longest common suffix of
+// the content of the nodes
+func commonSuffix(a, b *node) int {
+	next, stop := iter.Pull(func(yield func(string) bool) { b.walkBackward(yield) })
+	defer stop()
+
+	suffix := 0
+	rest := ""
+	a.walkBackward(func(value string) bool {
+		for value != "" {
+			if rest == "" {
+				var ok bool
+				if rest, ok = next(); !ok {
+					return false
+				}
+				continue
+			}
+
+			n := min(len(value), len(rest))
+			i := 0
+			for i < n && value[len(value)-1-i] == rest[len(rest)-1-i] {
+				i++
+			}
+			suffix += i
+			if i < n {
+				return false
+			}
+			value, rest = value[:len(value)-n], rest[:len(rest)-n]
+		}
+		return true
+	})
+	return suffix
+}
@@ -0,0 +1,164 @@
+package rope
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_ChangedRanges(t *testing.T) {
+	old := "one\ntwo\nthree\nfour\n"
+	tests := []struct {
+		name     string
+		current  string
+		expected []Range
+	}{
+		{"Unchanged", old, nil},
+		{"Start", "ONE\ntwo\nthree\nfour\n", []Range{{0, 3}}},
+		{"Middle", "one\ntwo\n3\nfour\n", []Range{{8, 9}}},
+		{"End", "one\ntwo\nthree\nfour!\n", []Range{{18, 19}}},
+		{"MiddleAndEnd", "one\ntwX\nthree\nfouX\n", []Range{{6, 8}, {14, 18}}},
+		{"InsertedAtStart", "zero\none\ntwo\nthree\nfour\n", []Range{{0, 5}}},
+		{"RemovedAtEnd", "one\ntwo\n", []Range{{8, 8}}},
+		{"RemovedLine", "one\nthree\nfour\n", []Range{{5, 5}}},
+		{"Emptied", "", []Range{{0, 0}}},
+		{"Unicode", "one\ntwö\nthree\nfour\n", []Range{{6, 8}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := CreateRope(test.current)
+			if ranges := r.ChangedRanges(old); !reflect.DeepEqual(ranges, test.expected) {
+				t.Fatalf("Incorrect ranges: expected %v, got %v", test.expected, ranges)
+			}
+		})
+	}
+}
+
+func Test_ChangedRanges_Large(t *testing.T) {
+	loopTest(t, "ChangedRanges", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		old := charSet.generator(stringSize.size)
+		r := CreateRope(old)
+		position := r.Length() / 2
+		r.Insert(position, "#changed#")
+
+		ranges := r.ChangedRanges(old)
+		if len(ranges) != 1 {
+			t.Fatalf("Expected 1 range, got %v", ranges)
+		}
+		start := len(string([]rune(old)[:position]))
+		if expected := (Range{start, start + len("#changed#")}); ranges[0] != expected {
+			t.Fatalf("Incorrect range: expected %v, got %v", expected, ranges[0])
+		}
+	})
+}
+
+func Test_Diff(t *testing.T) {
+	loopTest(t, "Diff", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		var lines []string
+		for i := 0; i < stringSize.size/10; i++ {
+			lines = append(lines, charSet.generator(i%9)+"\n")
+		}
+		init := strings.Join(lines, "")
+
+		edited := slices.Clone(lines)
+		edited[1] = "changed\n"
+		edited = slices.Insert(edited, len(edited)/2, "inserted\n", "🐿\n")
+		edited = slices.Delete(edited, len(edited)-4, len(edited)-2)
+
+		for _, target := range []string{init, strings.Join(edited, ""), "", init + "tail", "🐿" + init[1:]} {
+			a := CreateRope(init)
+			b := CreateRope(target)
+			edits := Diff(a, b)
+			if target == init && edits != nil {
+				t.Fatal("Expected no edits for the same content")
+			}
+			if err := a.ApplyEdits(edits); err != nil {
+				t.Fatal(err)
+			}
+			if a.String() != target {
+				t.Fatalf("Applying the diff did not give the target:\nExpected:\n'%+q'\nGet:\n'%+q'", target, a.String())
+			}
+		}
+	})
+
+	// A change of a single line is a single edit of that line
+	a := CreateRope("one\ntwo\nthree\n")
+	b := CreateRope("one\n2\nthree\n")
+	if edits := Diff(a, b); !reflect.DeepEqual(edits, []EditOp{{4, 7, "2"}}) {
+		t.Fatalf("Incorrect edits: %v", edits)
+	}
+
+	// Runes which differ only in their last byte are replaced whole
+	a = CreateRope("xé")
+	b = CreateRope("xè")
+	if edits := Diff(a, b); !reflect.DeepEqual(edits, []EditOp{{1, 2, "è"}}) {
+		t.Fatalf("Incorrect edits: %v", edits)
+	}
+}
+
+func Test_MatchLines(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		// Few distinct lines, so that there are many ways to match them
+		a := make([]string, rand.Intn(20))
+		b := make([]string, rand.Intn(20))
+		for j := range a {
+			a[j] = string(rune('a' + rand.Intn(3)))
+		}
+		for j := range b {
+			b[j] = string(rune('a' + rand.Intn(3)))
+		}
+
+		matches := matchLines(a, b)
+		for j, match := range matches {
+			if a[match[0]] != b[match[1]] {
+				t.Fatalf("Unequal lines matched in %q and %q", a, b)
+			}
+			if j > 0 && (match[0] <= matches[j-1][0] || match[1] <= matches[j-1][1]) {
+				t.Fatalf("Matches out of order in %q and %q", a, b)
+			}
+		}
+
+		// A shortest edit script keeps a longest common subsequence
+		lcs := make([][]int, len(a)+1)
+		for x := range lcs {
+			lcs[x] = make([]int, len(b)+1)
+		}
+		for x := len(a) - 1; x >= 0; x-- {
+			for y := len(b) - 1; y >= 0; y-- {
+				if a[x] == b[y] {
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				} else {
+					lcs[x][y] = max(lcs[x+1][y], lcs[x][y+1])
+				}
+			}
+		}
+		if len(matches) != lcs[0][0] {
+			t.Fatalf("Expected %d matches in %q and %q, got %d", lcs[0][0], a, b, len(matches))
+		}
+	}
+}
+
+func Test_MatchLines_Allocations(t *testing.T) {
+	a := make([]string, 3000)
+	b := make([]string, 3000)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d\n", i)
+		b[i] = fmt.Sprintf("b%d\n", i)
+	}
+
+	// Memory is linear in the lines even when every line differs
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if matches := matchLines(a, b); len(matches) != 0 {
+		t.Fatalf("Expected no matches, got %d", len(matches))
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("Matching 3000 changed lines allocated %d bytes", allocated)
+	}
+}
@@ -0,0 +1,133 @@
+package rope
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// EditOp is an edit of a Rope, which replaces the runes between Start and End
+// with Text.  An insert has an empty range, and a remove has no Text.
+type EditOp struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// AlterInverse returns the EditOp which undoes replacing the runes between
+// start and end with value.  It must be called before the edit is made, as
+// the replaced runes are read from the Rope.
+func (r *Rope) AlterInverse(start, end int, value string) (EditOp, error) {
+	if r == nil {
+		return EditOp{}, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if start < 0 || end > r.root.length {
+		return EditOp{}, fmt.Errorf("range [%d, %d): %w", start, end, ErrIndexOutOfRange)
+	}
+	if start > end {
+		return EditOp{}, fmt.Errorf("range [%d, %d): %w", start, end, ErrInvalidRange)
+	}
+
+	return EditOp{start, start + utf8.RuneCountInString(value), r.root.substring(start, end)}, nil
+}
+
+// Apply makes the edit described by the EditOp
+func (r *Rope) Apply(op EditOp) error {
+	return r.Alter(op.Start, op.End, op.Text)
+}
+
+// ApplyEdits makes all of the edits as one change of the Rope, as for the
+// output of a formatter.  The offsets of every edit refer to the Rope before
+// any of them is made, and edits which insert at the same offset are made in
+// the order given, before any edit which replaces a range from there.  The
+// edits are made from the last to the first, so the offsets of those still
+// to be made are undisturbed, Markers are moved for each of them, and the
+// tree is balanced once at the end.  If any edit is invalid, or any two
+// overlap, an error is returned and the Rope is left unchanged.
+func (r *Rope) ApplyEdits(edits []EditOp) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	for i, edit := range edits {
+		if edit.Start < 0 || edit.End > r.root.length {
+			return fmt.Errorf("edit %d: %w", i, ErrIndexOutOfRange)
+		}
+		if edit.Start > edit.End {
+			return fmt.Errorf("edit %d: %w", i, ErrInvalidRange)
+		}
+	}
+
+	return r.applyEdits(slices.Clone(edits))
+}
+
+// InsertInverse returns the EditOp which undoes inserting value at the given
+// rune offset: a remove of the inserted runes
+func (r *Rope) InsertInverse(position int, value string) EditOp {
+	return EditOp{position, position + utf8.RuneCountInString(value), ""}
+}
+
+// RemoveInverse returns the EditOp which undoes removing the runes between
+// start and end: an insert of the removed runes.  It must be called before
+// the edit is made, as the removed runes are read from the Rope.
+func (r *Rope) RemoveInverse(start, end int) (EditOp, error) {
+	return r.AlterInverse(start, end, "")
+}
+
+// applyEdits makes the edits, whose ranges have already been checked, as
+// ApplyEdits describes.  The slice is sorted in place.
+func (r *Rope) applyEdits(edits []EditOp) error {
+	// An insert at the start of a replaced range is made before it, in
+	// whichever order the two were given
+	slices.SortStableFunc(edits, func(a, b EditOp) int {
+		if a.Start != b.Start {
+			return cmp.Compare(a.Start, b.Start)
+		}
+		return cmp.Compare(min(a.End-a.Start, 1), min(b.End-b.Start, 1))
+	})
+	for i := 1; i < len(edits); i++ {
+		if edits[i].Start < edits[i-1].End {
+			return fmt.Errorf("edits overlap")
+		}
+	}
+
+	growth := 0
+	for _, edit := range edits {
+		growth += len(edit.Text) - (r.root.byteOffset(edit.End) - r.root.byteOffset(edit.Start))
+	}
+	if err := r.checkGrowth(growth); err != nil {
+		return err
+	}
+
+	if len(edits) == 0 {
+		return nil
+	}
+
+	r.root = r.root.mutable()
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		switch {
+		case edit.Start == edit.End && edit.Text == "":
+			continue
+		case edit.Start == edit.End:
+			r.root.insert(edit.Start, edit.Text)
+		case edit.Text == "":
+			r.root.remove(edit.Start, edit.End)
+		default:
+			r.root.alter(edit.Start, edit.End, edit.Text, nil)
+		}
+		inserted := utf8.RuneCountInString(edit.Text)
+		r.moveMarkers(edit.Start, edit.End, inserted)
+		r.notify(edit.Start, edit.End, inserted)
+	}
+	r.spine = nil
+	r.balance()
+	r.revision.Add(1)
+	return nil
+}
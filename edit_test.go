@@ -0,0 +1,130 @@
+package rope
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_EditOp_Inverse(t *testing.T) {
+	loopTest(t, "Inverse", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		length := stringSize.size
+		r := CreateRope(init)
+
+		ops := []EditOp{
+			{0, 0, "🐿 inserted"},
+			{length / 2, length / 2, charSet.generator(1000)},
+			{length, length, "x"},
+			{0, length / 3, ""},
+			{length / 3, length, ""},
+			{length / 4, length / 2, "Ω"},
+			{0, length, charSet.generator(700)},
+		}
+		for _, op := range ops {
+			var inverse EditOp
+			var err error
+			switch {
+			case op.Start == op.End:
+				inverse = r.InsertInverse(op.Start, op.Text)
+			case op.Text == "":
+				inverse, err = r.RemoveInverse(op.Start, op.End)
+			default:
+				inverse, err = r.AlterInverse(op.Start, op.End, op.Text)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.Apply(op); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Apply(inverse); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != init {
+				t.Fatalf("Applying the inverse of %d-%d did not restore the original", op.Start, op.End)
+			}
+		}
+	})
+}
+
+func Test_EditOp_InverseOutOfBounds(t *testing.T) {
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		start, end int
+		expected   error
+	}{{-1, 1, ErrIndexOutOfRange}, {0, 4, ErrIndexOutOfRange}, {2, 1, ErrInvalidRange}} {
+		if _, err := r.RemoveInverse(tc.start, tc.end); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+}
+
+func Test_ApplyEdits(t *testing.T) {
+	loopTest(t, "ApplyEdits", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		length := len(runes)
+		r := CreateRope(string(runes))
+		m, _ := r.Mark(length / 2)
+		revision := r.Revision()
+
+		// Given out of order, with two inserts at the same offset
+		edits := []EditOp{
+			{length / 2, length / 2, "b"},
+			{length - 5, length, ""},
+			{0, 3, "🐿"},
+			{length / 2, length / 2, "c"},
+			{length / 4, length / 3, "Ω"},
+		}
+		if err := r.ApplyEdits(edits); err != nil {
+			t.Fatal(err)
+		}
+		expected := "🐿" + string(runes[3:length/4]) + "Ω" + string(runes[length/3:length/2]) + "bc" + string(runes[length/2:length-5])
+		if r.String() != expected {
+			t.Fatalf("Incorrect result:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, r.String())
+		}
+		if r.Revision() != revision+1 {
+			t.Fatal("Edits were not made as one change")
+		}
+		if position, _ := m.Position(); position != len([]rune(expected))-(length/2-5)-2 {
+			t.Fatalf("Marker was not moved correctly: got %d", position)
+		}
+		if err := r.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r := CreateRope("abcdef")
+	for _, tc := range []struct {
+		edits    []EditOp
+		expected error
+	}{
+		{[]EditOp{{0, 1, "x"}, {-1, 2, "y"}}, ErrIndexOutOfRange},
+		{[]EditOp{{0, 1, "x"}, {4, 7, "y"}}, ErrIndexOutOfRange},
+		{[]EditOp{{3, 2, "x"}}, ErrInvalidRange},
+	} {
+		if err := r.ApplyEdits(tc.edits); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for %v, got %v", tc.expected, tc.edits, err)
+		}
+	}
+	if err := r.ApplyEdits([]EditOp{{0, 3, "x"}, {2, 4, "y"}}); err == nil {
+		t.Fatal("Expected error for overlapping edits")
+	}
+	if r.String() != "abcdef" || r.Revision() != 0 {
+		t.Fatal("Failed edits changed the rope")
+	}
+
+	// An insert next to a replace is made before it, in either order
+	for _, edits := range [][]EditOp{
+		{{2, 4, "y"}, {2, 2, "x"}},
+		{{2, 2, "x"}, {2, 4, "y"}},
+	} {
+		r := CreateRope("abcdef")
+		if err := r.ApplyEdits(edits); err != nil {
+			t.Fatalf("Edits %v failed: %v", edits, err)
+		}
+		if r.String() != "abxyef" {
+			t.Fatalf("Incorrect result for %v: %q", edits, r.String())
+		}
+	}
+}
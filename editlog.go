@@ -0,0 +1,102 @@
+package rope
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// EditLog records the changes made to a Rope as EditOps, in the order they
+// are made, for Replay to make again: to recover a document after a crash, or
+// to reproduce a reported bug exactly.  The offsets of each EditOp are those
+// of the content as it was just before it, as OnChange reports them, so the
+// ops are made one after another rather than as one batch.
+type EditLog struct {
+	ops  []EditOp
+	stop func()
+}
+
+// loggedOp is an EditOp as EditLog.WriteTo writes it.  The text is held as
+// bytes, which encoding/json writes as base64, so that text which is not
+// valid UTF-8 is kept exactly rather than replaced with U+FFFD.
+type loggedOp struct {
+	Start int
+	End   int
+	Text  []byte
+}
+
+// RecordEdits begins recording the changes made to the Rope.  The first op
+// of the log inserts the content which the Rope holds now, so that replaying
+// the log from an empty Rope gives the content of this one.
+func RecordEdits(r *Rope) *EditLog {
+	l := &EditLog{}
+	if !r.IsEmpty() {
+		l.ops = append(l.ops, EditOp{0, 0, r.String()})
+	}
+	l.stop = r.OnChange(func(e ChangeEvent) {
+		l.ops = append(l.ops, EditOp{e.Start, e.End, e.Text})
+	})
+	return l
+}
+
+// ReadEditLog reads the ops written by EditLog.WriteTo
+func ReadEditLog(r io.Reader) ([]EditOp, error) {
+	var ops []EditOp
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		var op loggedOp
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return nil, fmt.Errorf("op %d: %w", len(ops), err)
+		}
+		ops = append(ops, EditOp{op.Start, op.End, string(op.Text)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// Replay returns a new Rope made by applying the ops in turn to an empty
+// Rope, as recorded by an EditLog.  If an op is not within the bounds of the
+// content it is applied to, an error naming it is returned.
+func Replay(ops []EditOp) (*Rope, error) {
+	r := CreateRope("")
+	for i, op := range ops {
+		if err := r.Apply(op); err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+	}
+	return r, nil
+}
+
+// Ops returns the ops recorded so far
+func (l *EditLog) Ops() []EditOp {
+	return slices.Clone(l.ops)
+}
+
+// Stop ends the recording.  The ops recorded so far are kept.
+func (l *EditLog) Stop() {
+	l.stop()
+}
+
+// WriteTo writes the ops recorded so far to w as JSON, one op to a line, and
+// returns the number of bytes written.  The text of each op is base64, so the
+// content of a Rope which is not valid UTF-8 is replayed exactly.
+func (l *EditLog) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, op := range l.ops {
+		line, err := json.Marshal(loggedOp{op.Start, op.End, []byte(op.Text)})
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write(append(line, '\n'))
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
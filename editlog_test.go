@@ -0,0 +1,86 @@
+package rope
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_EditLog(t *testing.T) {
+	loopTest(t, "EditLog", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		l := RecordEdits(r)
+
+		r.Insert(stringSize.size/2, charSet.generator(100))
+		r.Remove(5, 20)
+		r.Alter(10, 12, "🐿\n")
+		r.InsertAtAll([]int{30, 0, 7}, "xy")
+		r.ApplyEdits([]EditOp{{1, 3, ""}, {8, 8, "🐿"}, {20, 25, "z"}})
+		r.Truncate(r.Length() - 10)
+		expected := r.String()
+		l.Stop()
+		r.Insert(0, "after")
+
+		var buf bytes.Buffer
+		n, err := l.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(buf.Len()) {
+			t.Fatalf("Incorrect count: expected %d, got %d", buf.Len(), n)
+		}
+		ops, err := ReadEditLog(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != len(l.Ops()) {
+			t.Fatalf("Incorrect number of ops read: expected %d, got %d", len(l.Ops()), len(ops))
+		}
+
+		replayed, err := Replay(ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed.String() != expected {
+			t.Fatal("Replay did not reproduce the content")
+		}
+		if err := replayed.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_EditLog_Errors(t *testing.T) {
+	l := RecordEdits(CreateRope(""))
+	if len(l.Ops()) != 0 {
+		t.Fatal("Empty rope recorded an op")
+	}
+
+	if _, err := Replay([]EditOp{{0, 0, "abc"}, {2, 5, ""}}); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("Expected ErrIndexOutOfRange for op out of bounds, got %v", err)
+	}
+	if _, err := ReadEditLog(bytes.NewBufferString("{\"Start\":0}\nnot json\n")); err == nil {
+		t.Fatal("Expected error for malformed log")
+	}
+}
+
+func Test_EditLog_InvalidUTF8(t *testing.T) {
+	r := CreateRopeFromBytes([]byte("a\xffb\xc3"))
+	l := RecordEdits(r)
+	r.Insert(1, "\xfe🐿")
+	r.Remove(4, 5)
+
+	var buf bytes.Buffer
+	l.WriteTo(&buf)
+	ops, err := ReadEditLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := Replay(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.String() != r.String() {
+		t.Fatalf("Replay did not reproduce invalid UTF-8: expected %q, got %q", r.String(), replayed.String())
+	}
+}
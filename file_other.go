@@ -0,0 +1,19 @@
+//go:build !unix
+
+package rope
+
+import (
+	"os"
+)
+
+// NewFileRope creates a Rope holding the contents of the file at the given
+// path.  On this platform the file cannot be memory-mapped, so its content is
+// read into memory up front.
+func NewFileRope(path string) (*Rope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateRopeFromBytes(data), nil
+}
@@ -0,0 +1,70 @@
+package rope
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_NewFileRope(t *testing.T) {
+	loopTest(t, "NewFileRope", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size * 10)
+		path := filepath.Join(t.TempDir(), "file.txt")
+		if err := os.WriteFile(path, []byte(init), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewFileRope(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Length() != stringSize.size*10 {
+			t.Fatalf("Incorrect length: expected %d, got %d", stringSize.size*10, r.Length())
+		}
+		if r.ByteLength() != len(init) {
+			t.Fatalf("Incorrect byte length: expected %d, got %d", len(init), r.ByteLength())
+		}
+		if result := r.String(); result != init {
+			t.Fatalf("Did not get file content back.\nexpected:\n%+q\ngot:\n%+q\n", init, result)
+		}
+		assertLogarithmicDepth(t, r)
+
+		r.Insert(stringSize.size, "a")
+		r.Remove(0, 1)
+		runes := []rune(init)
+		expected := string(runes[1:stringSize.size]) + "a" + string(runes[stringSize.size:])
+		if result := r.String(); result != expected {
+			t.Fatalf("Edit failed:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != init {
+			t.Fatal("Editing the rope altered the file")
+		}
+	})
+}
+
+func Test_NewFileRope_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewFileRope(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsEmpty() {
+		t.Fatalf("Rope from empty file is not empty: %q", r.String())
+	}
+}
+
+func Test_NewFileRope_Missing(t *testing.T) {
+	if _, err := NewFileRope(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("Expected error for missing file")
+	}
+}
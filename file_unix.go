@@ -0,0 +1,52 @@
+//go:build unix
+
+package rope
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// NewFileRope creates a Rope holding the contents of the file at the given
+// path.  Rather than reading the file, it is memory-mapped and the leaves of
+// the Rope refer directly into the mapping, so the operating system loads the
+// content on demand as it is read and may evict it again under memory
+// pressure.  This allows a Rope to hold a file larger than the available
+// memory.  Edits copy only the leaves which they touch into memory.
+//
+// The file is read once during construction to measure its runes and lines.
+//
+// The mapping is never released, as the leaves of this Rope, and of any Rope
+// which shares its structure, may refer to it for as long as they live.  The
+// Rope assumes that the file does not change while mapped: changes made by
+// other writers may become visible through the Rope at any time, leaving its
+// content inconsistent with its cached lengths, and truncating the file may
+// crash the program when the missing pages are read.
+func NewFileRope(path string) (*Rope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return CreateRope(""), nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file is too large to map")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateRopeFromBytes(data), nil
+}
@@ -0,0 +1,94 @@
+package rope
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VersionGraph names Versions of a document, and records the Version each
+// was made from, so that its history may fork: a tagged Version may be
+// branched into a new Rope, edited apart from every other branch, and its
+// Versions tagged in turn with the tag it was branched from as their parent.
+// The zero VersionGraph is empty and ready to use.
+//
+// A VersionGraph may be used from several goroutines at once.  As Versions
+// are immutable, a tagged Version may be read while a Rope branched from it,
+// or from any other, is edited; a reviewer may read an older Version while
+// the author keeps typing.  Ropes branched from the same tag may likewise be
+// edited from several goroutines at once, each Rope from one goroutine.
+type VersionGraph struct {
+	lock sync.RWMutex
+	tags map[string]taggedVersion
+}
+
+// taggedVersion is a Version of a VersionGraph, and the name of the tag it
+// was made from
+type taggedVersion struct {
+	version Version
+	parent  string
+}
+
+// Branch returns a new Rope holding the tagged Version, to edit apart from
+// the Version and from every other branch.  As with FromVersion, the Rope
+// shares its structure with the Version until it is edited.
+func (g *VersionGraph) Branch(name string) (*Rope, error) {
+	v, ok := g.Version(name)
+	if !ok {
+		return nil, fmt.Errorf("tag %q does not exist", name)
+	}
+	return FromVersion(v), nil
+}
+
+// Parent returns the name of the tag the tagged Version was made from, and
+// whether the tag exists.  A Version without a parent has the empty name.
+func (g *VersionGraph) Parent(name string) (string, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	t, ok := g.tags[name]
+	return t.parent, ok
+}
+
+// ReadAt returns the runes between start and end of the tagged Version, as
+// Rope.Substring does
+func (g *VersionGraph) ReadAt(name string, start, end int) (string, error) {
+	v, ok := g.Version(name)
+	if !ok {
+		return "", fmt.Errorf("tag %q does not exist", name)
+	}
+	return v.Substring(start, end)
+}
+
+// Tag names the Version, recording that it was made from the Version tagged
+// parent, or from none if parent is empty.  It is an error if the name is
+// empty or already tagged, or if the parent does not exist, so that the
+// history of a tag cannot change once it has been read.
+func (g *VersionGraph) Tag(name, parent string, v Version) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if name == "" {
+		return fmt.Errorf("tag name is empty")
+	}
+	if _, ok := g.tags[name]; ok {
+		return fmt.Errorf("tag %q already exists", name)
+	}
+	if _, ok := g.tags[parent]; parent != "" && !ok {
+		return fmt.Errorf("tag %q does not exist", parent)
+	}
+
+	if g.tags == nil {
+		g.tags = map[string]taggedVersion{}
+	}
+	g.tags[name] = taggedVersion{v, parent}
+	return nil
+}
+
+// Version returns the tagged Version, and whether the tag exists
+func (g *VersionGraph) Version(name string) (Version, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	t, ok := g.tags[name]
+	return t.version, ok
+}
@@ -0,0 +1,139 @@
+package rope
+
+import (
+	"sync"
+	"testing"
+)
+
+func Test_VersionGraph(t *testing.T) {
+	loopTest(t, "VersionGraph", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		var g VersionGraph
+		if err := g.Tag("v1", "", CreateRope(init).Root()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Two branches of v1, each edited and tagged
+		ours, err := g.Branch("v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		theirs, _ := g.Branch("v1")
+		ours.Insert(0, "🐿")
+		theirs.Remove(0, 10)
+		if err := g.Tag("ours", "v1", ours.Root()); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Tag("theirs", "v1", theirs.Root()); err != nil {
+			t.Fatal(err)
+		}
+		ours.Append("x")
+
+		expected := map[string]string{
+			"v1":     init,
+			"ours":   "🐿" + init,
+			"theirs": string([]rune(init)[10:]),
+		}
+		for name, content := range expected {
+			v, ok := g.Version(name)
+			if !ok || v.String() != content {
+				t.Fatalf("Tag %s does not hold its content", name)
+			}
+			s, err := g.ReadAt(name, 5, 15)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != string([]rune(content)[5:15]) {
+				t.Fatalf("Incorrect read of tag %s: %q", name, s)
+			}
+		}
+		if parent, _ := g.Parent("theirs"); parent != "v1" {
+			t.Fatalf("Incorrect parent: %q", parent)
+		}
+		if parent, ok := g.Parent("v1"); parent != "" || !ok {
+			t.Fatalf("Incorrect parent of root: %q", parent)
+		}
+	})
+}
+
+func Test_VersionGraph_Errors(t *testing.T) {
+	var g VersionGraph
+	v := CreateRope("abc").Root()
+	if err := g.Tag("", "", v); err == nil {
+		t.Fatal("Expected error for empty name")
+	}
+	if err := g.Tag("a", "missing", v); err == nil {
+		t.Fatal("Expected error for missing parent")
+	}
+	g.Tag("a", "", v)
+	if err := g.Tag("a", "", v); err == nil {
+		t.Fatal("Expected error for existing tag")
+	}
+	if _, err := g.Branch("missing"); err == nil {
+		t.Fatal("Expected error for missing tag")
+	}
+	if _, err := g.ReadAt("missing", 0, 1); err == nil {
+		t.Fatal("Expected error for missing tag")
+	}
+	if _, err := g.ReadAt("a", 0, 4); err == nil {
+		t.Fatal("Expected error for range outside the version")
+	}
+}
+
+func Test_VersionGraph_ConcurrentReads(t *testing.T) {
+	init := generateASCIIString(100000)
+	var g VersionGraph
+	author := CreateRope(init)
+	g.Tag("review", "", author.Root())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s, err := g.ReadAt("review", j*100, j*100+500)
+				if err != nil || s != init[j*100:j*100+500] {
+					t.Error("Tagged version changed while read")
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		author.Insert(i*400, "x")
+	}
+	wg.Wait()
+}
+
+func Test_VersionGraph_ConcurrentBranches(t *testing.T) {
+	init := generateASCIIString(100000)
+	var g VersionGraph
+	g.Tag("v1", "", CreateRope(init).Root())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			branch, err := g.Branch("v1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < 100; j++ {
+				branch.Insert(j*900, "x")
+				branch.Remove(j*500, j*500+1)
+			}
+			if branch.Length() != len(init) {
+				t.Error("Incorrect length after edits")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s, _ := g.ReadAt("v1", 0, len(init)); s != init {
+		t.Fatal("Tagged version changed by edits to its branches")
+	}
+}
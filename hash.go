@@ -0,0 +1,30 @@
+package rope
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// ContentID returns the hex-encoded SHA-256 digest of the content of the
+// Rope, which depends only on the content and not on the shape of the tree.
+// The digest is computed leaf by leaf, and kept until the next edit, so
+// repeated calls on an unchanged Rope do not read it again.
+func (r *Rope) ContentID() string {
+	revision := r.revision.Load()
+	if r.contentID == "" || r.contentIDRevision != revision {
+		h := sha256.New()
+		r.root.walk(func(value string) bool {
+			io.WriteString(h, value)
+			return true
+		})
+		id := hex.EncodeToString(h.Sum(nil))
+		if r.frozen {
+			// A frozen Rope may be read concurrently, so it is not written
+			return id
+		}
+		r.contentID = id
+		r.contentIDRevision = revision
+	}
+	return r.contentID
+}
@@ -0,0 +1,52 @@
+package rope
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func Test_ContentID(t *testing.T) {
+	loopTest(t, "ContentID", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		sum := sha256.Sum256([]byte(init))
+		expected := hex.EncodeToString(sum[:])
+
+		r := CreateRope(init)
+		if id := r.ContentID(); id != expected {
+			t.Fatalf("Incorrect ID: expected %s, got %s", expected, id)
+		}
+
+		// The same content built by appending has a different shape.
+		appended := CreateRope("")
+		runes := []rune(init)
+		for i := 0; i < len(runes); i += 7 {
+			appended.Append(string(runes[i:min(i+7, len(runes))]))
+		}
+		if appended.Shape() == r.Shape() && stringSize.size > splitLength {
+			t.Fatalf("Expected ropes of different shapes")
+		}
+		if id := appended.ContentID(); id != expected {
+			t.Fatalf("Incorrect ID for appended rope: expected %s, got %s", expected, id)
+		}
+
+		r.Insert(0, "x")
+		if id := r.ContentID(); id == expected {
+			t.Fatalf("ID was not updated by an edit")
+		}
+		r.Remove(0, 1)
+		if id := r.ContentID(); id != expected {
+			t.Fatalf("Incorrect ID after undoing an edit: expected %s, got %s", expected, id)
+		}
+	})
+}
+
+func Benchmark_ContentID(b *testing.B) {
+	r := CreateRope(generateASCIIString(200000))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.ContentID()
+	}
+}
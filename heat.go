@@ -0,0 +1,75 @@
+package rope
+
+// EditHeat returns a histogram of the edits made to the Rope, dividing it
+// into the given number of regions of equal rune length and counting the
+// edits which touched each.  Every leaf of the tree counts the mutations
+// which have touched it, and when a leaf is split or joined its count is
+// shared out or summed by length, so the counts follow the text they were
+// made to.  An edit which spans several leaves counts once in each.
+// SetContent and BalanceWithFill build a new tree, and so clear the counts.
+func (r *Rope) EditHeat(buckets int) []int {
+	if buckets < 1 {
+		return nil
+	}
+
+	heat := make([]int, buckets)
+	if r.root.length > 0 {
+		r.root.heat(0, r.root.length, heat)
+	}
+	return heat
+}
+
+// heat adds the edits of the leaves under the node, which begins at the rune
+// offset, to the buckets dividing a Rope of total runes.  The edits of a leaf
+// are shared between the buckets it overlaps by the length of the overlap.
+func (n *node) heat(offset, total int, heat []int) {
+	if n.edits == 0 {
+		return
+	}
+
+	if n.value == nil {
+		n.left.heat(offset, total, heat)
+		n.right.heat(offset+n.left.length, total, heat)
+		return
+	}
+
+	if n.length == 0 {
+		heat[min(offset*len(heat)/total, len(heat)-1)] += n.edits
+		return
+	}
+
+	// Assign the edits before each bucket boundary within the leaf
+	// cumulatively, so that none are lost to rounding
+	end := offset + n.length
+	assigned := 0
+	for b := offset * len(heat) / total; b < len(heat) && assigned < n.edits; b++ {
+		boundary := min((b+1)*total/len(heat), end)
+		if boundary <= offset {
+			continue
+		}
+		upTo := n.edits * (boundary - offset) / n.length
+		if b == len(heat)-1 || boundary == end {
+			upTo = n.edits
+		}
+		heat[b] += upTo - assigned
+		assigned = upTo
+	}
+}
+
+// spreadEdits shares the edits out between the leaves by their lengths
+func spreadEdits(leaves []*node, edits int) {
+	total := 0
+	for _, leaf := range leaves {
+		total += leaf.length
+	}
+	if total == 0 {
+		leaves[0].edits += edits
+		return
+	}
+
+	before := 0
+	for _, leaf := range leaves {
+		leaf.edits += edits*(before+leaf.length)/total - edits*before/total
+		before += leaf.length
+	}
+}
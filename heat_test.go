@@ -0,0 +1,59 @@
+package rope
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_EditHeat(t *testing.T) {
+	r := CreateRope(generateASCIIString(10000))
+	if heat := r.EditHeat(10); !reflect.DeepEqual(heat, make([]int, 10)) {
+		t.Fatalf("Expected no heat for a new rope, got %v", heat)
+	}
+
+	for i := 0; i < 20; i++ {
+		r.Insert(100, "abc")
+	}
+	for i := 0; i < 5; i++ {
+		r.Remove(9500, 9510)
+	}
+	for i := 0; i < 3; i++ {
+		r.Append("xyz")
+	}
+
+	expected := []int{20, 0, 0, 0, 0, 0, 0, 0, 0, 8}
+	if heat := r.EditHeat(10); !reflect.DeepEqual(heat, expected) {
+		t.Fatalf("Incorrect heat: expected %v, got %v", expected, heat)
+	}
+	if heat := r.EditHeat(1); heat[0] != 28 {
+		t.Fatalf("Incorrect total heat: expected 28, got %d", heat[0])
+	}
+	if heat := r.EditHeat(0); heat != nil {
+		t.Fatalf("Expected no buckets, got %v", heat)
+	}
+
+	r.SetContent(generateASCIIString(1000))
+	if heat := r.EditHeat(4); !reflect.DeepEqual(heat, make([]int, 4)) {
+		t.Fatalf("Expected no heat after SetContent, got %v", heat)
+	}
+}
+
+func Test_EditHeat_Splits(t *testing.T) {
+	loopTest(t, "EditHeat", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope("")
+		edits := 0
+		for r.Length() < 20*stringSize.size {
+			r.Insert(r.Length()/3, charSet.generator(stringSize.size/10))
+			edits++
+		}
+		r.Alter(0, r.Length(), charSet.generator(stringSize.size))
+
+		total := 0
+		for _, count := range r.EditHeat(7) {
+			total += count
+		}
+		if total < edits {
+			t.Fatalf("Lost edits: expected at least %d, got %d", edits, total)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package rope
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// History records the edits made to a Rope through it, so that they can be
+// undone and redone.  Each step of the history is one edit, except that
+// keystrokes are grouped: an insert of a single rune at the end of the
+// previous insert, or a remove of a single rune next to the previous remove,
+// joins the step before it.  A newline, or a call to EndGroup, ends the
+// group.
+//
+// Edits made to the Rope other than through the History move the content
+// out from under its steps, so the History is cleared when it finds that
+// the Rope has been edited elsewhere.
+type History struct {
+	r        *Rope
+	undo     []historyStep
+	redo     []historyStep
+	revision uint64
+	grouping bool
+}
+
+// historyStep is a single step of a History: the edits which made it, in the
+// order they were made, and the edits which undo each of them
+type historyStep struct {
+	edits    []EditOp
+	inverses []EditOp
+}
+
+// WithHistory returns a History recording the edits made to the Rope through
+// it
+func WithHistory(r *Rope) *History {
+	return &History{r: r, revision: r.Revision()}
+}
+
+// Alter replaces the runes between start and end with the value, as
+// Rope.Alter does, and records the edit
+func (h *History) Alter(start, end int, value string) error {
+	inverse, err := h.r.AlterInverse(start, end, value)
+	if err != nil {
+		return err
+	}
+	return h.record(EditOp{start, end, value}, inverse, false)
+}
+
+// CanRedo reports whether there is an undone step to redo
+func (h *History) CanRedo() bool {
+	h.sync()
+	return len(h.redo) > 0
+}
+
+// CanUndo reports whether there is a step to undo
+func (h *History) CanUndo() bool {
+	h.sync()
+	return len(h.undo) > 0
+}
+
+// EndGroup ends the current group of keystrokes, so that the next edit
+// begins a new step
+func (h *History) EndGroup() {
+	h.grouping = false
+}
+
+// Insert adds the value at the given rune offset, as Rope.Insert does, and
+// records the edit
+func (h *History) Insert(position int, value string) error {
+	if position < 0 || position > h.r.Length() {
+		return ErrIndexOutOfRange
+	}
+
+	inverse := h.r.InsertInverse(position, value)
+	keystroke := utf8.RuneCountInString(value) == 1 && value != "\n"
+	return h.record(EditOp{position, position, value}, inverse, keystroke)
+}
+
+// Redo makes the most recently undone step again.  It is an error if there
+// is none.
+func (h *History) Redo() error {
+	if !h.CanRedo() {
+		return fmt.Errorf("nothing to redo")
+	}
+
+	step := h.redo[len(h.redo)-1]
+	for _, edit := range step.edits {
+		if err := h.r.Apply(edit); err != nil {
+			return err
+		}
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, step)
+	h.revision = h.r.Revision()
+	h.grouping = false
+	return nil
+}
+
+// Remove deletes the runes between start and end, as Rope.Remove does, and
+// records the edit
+func (h *History) Remove(start, end int) error {
+	inverse, err := h.r.RemoveInverse(start, end)
+	if err != nil {
+		return err
+	}
+	return h.record(EditOp{start, end, ""}, inverse, end-start == 1)
+}
+
+// Rope returns the Rope whose edits are recorded
+func (h *History) Rope() *Rope {
+	return h.r
+}
+
+// Undo reverts the most recent step.  It is an error if there is none.
+func (h *History) Undo() error {
+	if !h.CanUndo() {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	step := h.undo[len(h.undo)-1]
+	for i := len(step.inverses) - 1; i >= 0; i-- {
+		if err := h.r.Apply(step.inverses[i]); err != nil {
+			return err
+		}
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, step)
+	h.revision = h.r.Revision()
+	h.grouping = false
+	return nil
+}
+
+// record makes the edit and adds it to the history, joining it to the last
+// step if both are keystrokes next to each other
+func (h *History) record(edit, inverse EditOp, keystroke bool) error {
+	h.sync()
+	if err := h.r.Apply(edit); err != nil {
+		return err
+	}
+	h.revision = h.r.Revision()
+	h.redo = nil
+
+	if keystroke && h.grouping && len(h.undo) > 0 {
+		step := &h.undo[len(h.undo)-1]
+		if adjacent(step.edits[len(step.edits)-1], edit) {
+			step.edits = append(step.edits, edit)
+			step.inverses = append(step.inverses, inverse)
+			return nil
+		}
+	}
+
+	h.undo = append(h.undo, historyStep{[]EditOp{edit}, []EditOp{inverse}})
+	h.grouping = keystroke
+	return nil
+}
+
+// sync clears the history if the Rope has been edited other than through it
+func (h *History) sync() {
+	if revision := h.r.Revision(); revision != h.revision {
+		h.undo = nil
+		h.redo = nil
+		h.revision = revision
+		h.grouping = false
+	}
+}
+
+// adjacent reports whether the keystroke continues the previous one: an
+// insert at the end of the previous insert, or a remove which ends where the
+// previous remove began, as by backspace, or begins there, as by delete
+func adjacent(previous, edit EditOp) bool {
+	if edit.Start == edit.End {
+		return previous.Start == previous.End && edit.Start == previous.Start+utf8.RuneCountInString(previous.Text)
+	}
+
+	return previous.Text == "" && previous.Start < previous.End && (edit.End == previous.Start || edit.Start == previous.Start)
+}
@@ -0,0 +1,117 @@
+package rope
+
+import (
+	"testing"
+)
+
+func Test_History(t *testing.T) {
+	loopTest(t, "History", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		length := stringSize.size
+		r := CreateRope(init)
+		h := WithHistory(r)
+
+		var contents []string
+		for i, edit := range []func() error{
+			func() error { return h.Insert(length/2, charSet.generator(100)) },
+			func() error { return h.Remove(0, length/3) },
+			func() error { return h.Alter(10, 20, "🐿") },
+			func() error { return h.Insert(r.Length(), "\n") },
+		} {
+			contents = append(contents, r.String())
+			if err := edit(); err != nil {
+				t.Fatalf("Edit %d failed: %v", i, err)
+			}
+		}
+		final := r.String()
+
+		for i := len(contents) - 1; i >= 0; i-- {
+			if err := h.Undo(); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != contents[i] {
+				t.Fatalf("Undo did not restore step %d", i)
+			}
+		}
+		if h.CanUndo() || h.Undo() == nil {
+			t.Fatal("Expected nothing to undo")
+		}
+
+		for i := 1; i < len(contents); i++ {
+			if err := h.Redo(); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != contents[i] {
+				t.Fatalf("Redo did not restore step %d", i)
+			}
+		}
+		if err := h.Redo(); err != nil || r.String() != final {
+			t.Fatal("Redo did not restore the final content")
+		}
+		if h.CanRedo() || h.Redo() == nil {
+			t.Fatal("Expected nothing to redo")
+		}
+	})
+}
+
+func Test_History_Grouping(t *testing.T) {
+	r := CreateRope("ab")
+	h := WithHistory(r)
+
+	// Typed runes are one step, which a newline ends
+	for i, ru := range "hello" {
+		h.Insert(1+i, string(ru))
+	}
+	h.Insert(6, "\n")
+	h.Insert(7, "🐿")
+	h.Insert(8, "x")
+	if r.String() != "ahello\n🐿xb" {
+		t.Fatalf("Incorrect content: %q", r.String())
+	}
+
+	// Backspaces and deletes are each one step, after typing in another place
+	h.Remove(8, 9)
+	h.Remove(7, 8)
+	h.Insert(0, "z")
+	h.Remove(1, 2)
+	h.Remove(1, 2)
+	if r.String() != "zello\nb" {
+		t.Fatalf("Incorrect content: %q", r.String())
+	}
+
+	for _, expected := range []string{"zahello\nb", "ahello\nb", "ahello\n🐿xb", "ahello\nb", "ahellob", "ab"} {
+		if err := h.Undo(); err != nil {
+			t.Fatal(err)
+		}
+		if r.String() != expected {
+			t.Fatalf("Incorrect undo: expected %q, got %q", expected, r.String())
+		}
+	}
+
+	// EndGroup splits adjacent keystrokes
+	h.Insert(1, "x")
+	h.EndGroup()
+	h.Insert(2, "y")
+	h.Undo()
+	if r.String() != "axb" {
+		t.Fatalf("EndGroup did not end the group: %q", r.String())
+	}
+}
+
+func Test_History_External_Edit(t *testing.T) {
+	r := CreateRope("abc")
+	h := WithHistory(r)
+	h.Insert(0, "x")
+	h.Undo()
+	r.Insert(0, "y")
+	if h.CanUndo() || h.CanRedo() {
+		t.Fatal("History was not cleared by an edit made elsewhere")
+	}
+
+	if err := h.Insert(5, "x"); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if h.CanUndo() {
+		t.Fatal("Failed insert was recorded")
+	}
+}
@@ -0,0 +1,124 @@
+package rope
+
+import (
+	"fmt"
+	"strings"
+)
+
+// indentSampleLines is the number of indented lines examined by DetectIndent
+const indentSampleLines = 1000
+
+// DetectIndent infers whether the Rope is indented with tabs or with spaces,
+// from the leading whitespace of up to the first thousand indented lines.
+// Only the start of each line is examined; the rest is skipped.  For spaces,
+// width is the most common change in indentation between consecutive lines,
+// from 2 to 8, so a document indented in steps of four spaces reports 4.  For
+// tabs, or if no indentation is found, width is 0.
+func (r *Rope) DetectIndent() (useTabs bool, width int) {
+	tabs, spaces := 0, 0
+	var steps [9]int
+	previous := 0
+
+	// The whitespace at the start of a line may span leaves, so the state of
+	// the current line is carried between them.
+	prefix, leadingTab, mixed, count := true, false, false, 0
+	r.root.walk(func(value string) bool {
+		for i := 0; i < len(value); i++ {
+			if !prefix {
+				next := strings.IndexByte(value[i:], '\n')
+				if next < 0 {
+					break
+				}
+				i += next
+				prefix, leadingTab, mixed, count = true, false, false, 0
+				continue
+			}
+
+			switch value[i] {
+			case ' ':
+				count++
+				continue
+			case '\t':
+				if count == 0 {
+					leadingTab = true
+				} else if !leadingTab {
+					mixed = true
+				}
+				count++
+				continue
+			case '\n', '\r':
+				// Blank lines say nothing about indentation.
+				prefix, leadingTab, mixed, count = true, false, false, 0
+				continue
+			}
+
+			prefix = false
+			switch {
+			case leadingTab:
+				tabs++
+			case mixed:
+				// Tabs following spaces are ambiguous, so are ignored.
+			default:
+				if count > 0 {
+					spaces++
+				}
+				step := count - previous
+				if step < 0 {
+					step = -step
+				}
+				if step >= 2 && step < len(steps) {
+					steps[step]++
+				}
+				previous = count
+			}
+			if tabs+spaces >= indentSampleLines {
+				return false
+			}
+		}
+		return true
+	})
+
+	if tabs > spaces {
+		return true, 0
+	}
+	for step, n := range steps {
+		if n > steps[width] {
+			width = step
+		}
+	}
+	return false, width
+}
+
+// LineIndent returns the column at which the text of the 0-based line begins,
+// counting its leading spaces as one column each and advancing to the next
+// multiple of tabWidth for each tab.  The line is found from the newline
+// counts of the tree, and only its leading whitespace is read.
+func (r *Rope) LineIndent(line int, tabWidth int) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if line < 0 || line > r.root.newlines {
+		return 0, fmt.Errorf("line is not within rope bounds")
+	}
+
+	if tabWidth < 1 {
+		return 0, fmt.Errorf("tabWidth must be positive")
+	}
+
+	column := 0
+	r.root.walkRange(r.root.lineStart(line), r.root.lineEnd(line), func(value string) bool {
+		for i := 0; i < len(value); i++ {
+			switch value[i] {
+			case ' ':
+				column++
+			case '\t':
+				column += tabWidth - column%tabWidth
+			default:
+				return false
+			}
+		}
+		return true
+	})
+	return column, nil
+}
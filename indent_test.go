@@ -0,0 +1,80 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_DetectIndent(t *testing.T) {
+	tests := []struct {
+		name    string
+		init    string
+		useTabs bool
+		width   int
+	}{
+		{"empty", "", false, 0},
+		{"unindented", "a\nb\nc\n", false, 0},
+		{"tabs", "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n", true, 0},
+		{"two-spaces", "a:\n  b:\n    c: 1\n    d: 2\n  e: 3\nf: 4\n", false, 2},
+		{"four-spaces", "def f():\n    if x:\n        y()\n\n    return z\n", false, 4},
+		{"four-spaces-crlf", "def f():\r\n    if x:\r\n        y()\r\n\r\n    return z\r\n", false, 4},
+		{"mostly-tabs", "a\n\tb\n\tc\n    d\n\te\n", true, 0},
+		{"mostly-spaces", "a\n   b\n   c\n\td\n   e\n", false, 3},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useTabs, width := CreateRope(tc.init).DetectIndent()
+			if useTabs != tc.useTabs || width != tc.width {
+				t.Fatalf("Incorrect indent: expected %t/%d, got %t/%d", tc.useTabs, tc.width, useTabs, width)
+			}
+		})
+	}
+}
+
+func Test_DetectIndent_Large(t *testing.T) {
+	for _, indent := range []string{"\t", "  ", "    "} {
+		var b strings.Builder
+		for i := 0; i < 2000; i++ {
+			b.WriteString(strings.Repeat(indent, i%5))
+			b.WriteString(generateUnicodeString(100))
+			b.WriteString("\n")
+		}
+		r := CreateRope(b.String())
+
+		useTabs, width := r.DetectIndent()
+		if indent == "\t" && !useTabs {
+			t.Fatalf("Expected tabs")
+		}
+		if indent != "\t" && (useTabs || width != len(indent)) {
+			t.Fatalf("Incorrect indent for %q: got %t/%d", indent, useTabs, width)
+		}
+	}
+}
+
+func Test_LineIndent(t *testing.T) {
+	r := CreateRope("none\n    four\n\ttab\n  \tmixed\n\t  after\n   \n" + strings.Repeat(" ", 1500) + "deep")
+	for _, tc := range []struct {
+		line, tabWidth, expected int
+	}{
+		{0, 4, 0},
+		{1, 4, 4},
+		{2, 4, 4},
+		{2, 8, 8},
+		{3, 4, 4},
+		{3, 2, 4},
+		{4, 4, 6},
+		{5, 4, 3},
+		{6, 4, 1500},
+	} {
+		if indent, err := r.LineIndent(tc.line, tc.tabWidth); err != nil || indent != tc.expected {
+			t.Fatalf("Incorrect indent of line %d with tab width %d: expected %d, got %d, %v", tc.line, tc.tabWidth, tc.expected, indent, err)
+		}
+	}
+
+	for _, tc := range [][2]int{{-1, 4}, {7, 4}, {0, 0}} {
+		if _, err := r.LineIndent(tc[0], tc[1]); err == nil {
+			t.Fatalf("Expected error for line %d with tab width %d", tc[0], tc[1])
+		}
+	}
+}
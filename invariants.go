@@ -0,0 +1,131 @@
+package rope
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// CheckInvariants verifies the structure of the tree, and returns an error
+// describing the first problem found.  Every leaf must hold a value and no
+// children, and every internal node two children and no value, and the rune,
+// byte and newline counts and the depth cached at each node must match its
+// content.
+func (r *Rope) CheckInvariants() error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	return r.root.check("root")
+}
+
+// Recompute derives the rune, byte and newline counts and the depth of every
+// node again from the content of the leaves, repairing any which are wrong.
+// The counts are only ever wrong through a bug, which CheckInvariants can
+// detect.  As with any edit, nodes shared with other Ropes or Versions are
+// copied rather than repaired in place, so only this Rope is repaired.
+// ErrFrozen is returned if the Rope is frozen.
+func (r *Rope) Recompute() error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	r.root = r.root.recompute(false)
+	r.spine = nil
+	return nil
+}
+
+// check verifies the node and its descendants, naming the node by its path
+// in any error
+func (n *node) check(path string) error {
+	if n.value != nil {
+		if n.left != nil || n.right != nil {
+			return fmt.Errorf("%s: leaf has children", path)
+		}
+		value := *n.value
+		if length := utf8.RuneCountInString(value); n.length != length {
+			return fmt.Errorf("%s: length is %d, but leaf holds %d runes", path, n.length, length)
+		}
+		if n.byteLength != len(value) {
+			return fmt.Errorf("%s: byte length is %d, but leaf holds %d bytes", path, n.byteLength, len(value))
+		}
+		if newlines := strings.Count(value, "\n"); n.newlines != newlines {
+			return fmt.Errorf("%s: newline count is %d, but leaf holds %d newlines", path, n.newlines, newlines)
+		}
+		if n.depth != 0 {
+			return fmt.Errorf("%s: leaf has depth %d", path, n.depth)
+		}
+		return nil
+	}
+
+	if n.left == nil || n.right == nil {
+		return fmt.Errorf("%s: internal node is missing a child", path)
+	}
+	if err := n.left.check(path + ".left"); err != nil {
+		return err
+	}
+	if err := n.right.check(path + ".right"); err != nil {
+		return err
+	}
+
+	if length := n.left.length + n.right.length; n.length != length {
+		return fmt.Errorf("%s: length is %d, but children hold %d runes", path, n.length, length)
+	}
+	if byteLength := n.left.byteLength + n.right.byteLength; n.byteLength != byteLength {
+		return fmt.Errorf("%s: byte length is %d, but children hold %d bytes", path, n.byteLength, byteLength)
+	}
+	if newlines := n.left.newlines + n.right.newlines; n.newlines != newlines {
+		return fmt.Errorf("%s: newline count is %d, but children hold %d newlines", path, n.newlines, newlines)
+	}
+	if depth := 1 + max(n.left.depth, n.right.depth); n.depth != depth {
+		return fmt.Errorf("%s: depth is %d, but should be %d", path, n.depth, depth)
+	}
+	return nil
+}
+
+// recompute returns the node with the counts of it and its descendants
+// derived from the content of its leaves.  A node whose counts are wrong is
+// copied if it, or any node above it, is shared, and otherwise repaired in
+// place.
+func (n *node) recompute(shared bool) *node {
+	shared = shared || n.shared.Load()
+	if n.value != nil {
+		value := *n.value
+		length := utf8.RuneCountInString(value)
+		newlines := strings.Count(value, "\n")
+		if n.length == length && n.byteLength == len(value) && n.newlines == newlines && n.depth == 0 {
+			return n
+		}
+
+		if shared {
+			n = n.clone()
+		}
+		n.length = length
+		n.byteLength = len(value)
+		n.newlines = newlines
+		n.depth = 0
+		return n
+	}
+
+	left := n.left.recompute(shared)
+	right := n.right.recompute(shared)
+	if left == n.left && right == n.right &&
+		n.length == left.length+right.length &&
+		n.byteLength == left.byteLength+right.byteLength &&
+		n.newlines == left.newlines+right.newlines &&
+		n.depth == 1+max(left.depth, right.depth) {
+		return n
+	}
+
+	if shared {
+		n = n.clone()
+	}
+	n.left = left
+	n.right = right
+	n.recount()
+	return n
+}
@@ -0,0 +1,84 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_CheckInvariants(t *testing.T) {
+	loopTest(t, "CheckInvariants", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		r.Insert(stringSize.size/2, charSet.generator(1000)+"\n")
+		r.Remove(10, 300)
+		r.Append("\n")
+		p, _ := r.Prefix(r.Length() / 2)
+		p.Insert(0, "x")
+
+		for _, rope := range []*Rope{r, p} {
+			if err := rope.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func Test_Recompute(t *testing.T) {
+	corruptions := []struct {
+		name    string
+		corrupt func(n *node)
+		message string
+	}{
+		{"length", func(n *node) { n.length++ }, "length is"},
+		{"byteLength", func(n *node) { n.byteLength-- }, "byte length is"},
+		{"newlines", func(n *node) { n.newlines += 3 }, "newline count is"},
+		{"depth", func(n *node) { n.depth = 7 }, "depth"},
+	}
+
+	init := strings.Repeat(generateUnicodeString(99)+"\n", 30)
+	for _, tc := range corruptions {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope(init)
+			for _, leaf := range []*node{r.root, r.root.left.right, r.root.leaves(nil, false)[3]} {
+				tc.corrupt(leaf)
+
+				err := r.CheckInvariants()
+				if err == nil || !strings.Contains(err.Error(), tc.message) {
+					t.Fatalf("Expected error about %s, got %v", tc.name, err)
+				}
+
+				if err := r.Recompute(); err != nil {
+					t.Fatal(err)
+				}
+				if err := r.CheckInvariants(); err != nil {
+					t.Fatal(err)
+				}
+				if r.String() != init || r.Length() != CreateRope(init).Length() || r.LineCount() != 31 {
+					t.Fatal("Recompute did not restore the counts")
+				}
+			}
+		})
+	}
+}
+
+func Test_Recompute_Shared(t *testing.T) {
+	init := strings.Repeat(generateUnicodeString(99)+"\n", 30)
+	r := CreateRope(init)
+	v := r.Root()
+	r.root.leaves(nil, false)[3].length++
+
+	// The corrupt leaf is copied for the Rope, and the Version is not written
+	if err := r.Recompute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if err := FromVersion(v).CheckInvariants(); err == nil {
+		t.Fatal("Recompute wrote a node shared with a Version")
+	}
+
+	r.Freeze()
+	if err := r.Recompute(); err != ErrFrozen {
+		t.Fatalf("Expected ErrFrozen, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package rope
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ColumnBlock returns the rectangular selection of the lines from startLine
+// to endLine inclusive and the rune columns from startCol up to endCol: for
+// each line, its runes in that range of columns.  A line which ends before
+// endCol gives those of its runes which are in range, and one which ends
+// before startCol gives an empty string.  Each line is found from the
+// newline counts of the tree, so only the selected runes are read.
+func (r *Rope) ColumnBlock(startLine, endLine, startCol, endCol int) ([]string, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if startLine < 0 || endLine > r.root.newlines || startLine > endLine {
+		return nil, fmt.Errorf("lines are not within rope bounds")
+	}
+
+	if startCol < 0 || startCol > endCol {
+		return nil, fmt.Errorf("columns are not a valid range")
+	}
+
+	block := make([]string, 0, endLine-startLine+1)
+	for line := startLine; line <= endLine; line++ {
+		start := r.root.lineStart(line)
+		length := r.root.lineEnd(line) - start
+		block = append(block, r.root.substring(start+min(startCol, length), start+min(endCol, length)))
+	}
+	return block, nil
+}
+
+// CountLinesFunc returns the number of lines in the Rope for which f returns
+// true.  Lines are passed to f without their newlines, and are as counted by
+// LineCount, so a document which ends with a newline has an empty final line.
+// Lines are read one at a time, and only a line which spans leaves is copied.
+func (r *Rope) CountLinesFunc(f func(line string) bool) int {
+	count := 0
+	r.walkLines(func(line string) bool {
+		if f(line) {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// InsertLineAfter adds a new line holding the indent followed by the text
+// after the given 0-based line.  The newline separating the two lines is
+// placed at the end of the existing line, so a trailing newline at the end of
+// the document is preserved and the lines after the new one are each shifted
+// down by one.
+func (r *Rope) InsertLineAfter(line int, text string, indent string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if line < 0 || line > r.root.newlines {
+		return fmt.Errorf("line is not within rope bounds")
+	}
+
+	return r.Insert(r.root.lineEnd(line), "\n"+indent+text)
+}
+
+// LineCount returns the number of lines in the Rope, which is one more than
+// the number of newlines it holds.  A document which ends with a newline
+// therefore has an empty final line, and an empty document has a single
+// empty line.
+func (r *Rope) LineCount() int {
+	return r.root.newlines + 1
+}
+
+// LineNumberWidth returns the number of digits in the largest line number,
+// counting lines from 1, so that a gutter of line numbers can be aligned.  It
+// is derived from the cached count of newlines, so is constant time.
+func (r *Rope) LineNumberWidth() int {
+	return len(strconv.Itoa(r.LineCount()))
+}
+
+// LineStarts returns the rune offset of the start of every line in the Rope,
+// in a single pass over its leaves.  There is one entry for each line counted
+// by LineCount, so when the document ends with a newline the final entry is
+// Length(), the start of the empty final line.
+func (r *Rope) LineStarts() []int {
+	starts := make([]int, 1, r.LineCount())
+	offset := 0
+	r.root.walk(func(value string) bool {
+		for _, ru := range value {
+			offset++
+			if ru == '\n' {
+				starts = append(starts, offset)
+			}
+		}
+		return true
+	})
+	return starts
+}
+
+// NextNewline returns the rune offset of the first newline at or after the
+// given offset, which is the end of the line holding it, and whether there is
+// one.  The newline is found from the cached newline counts of the tree, so
+// only the leaves at either end are scanned.
+func (r *Rope) NextNewline(offset int) (int, bool) {
+	k := r.root.newlinesBefore(min(max(offset, 0), r.root.length))
+	if k == r.root.newlines {
+		return 0, false
+	}
+
+	return r.root.findNewline(k + 1), true
+}
+
+// PrevNewline returns the rune offset of the last newline before the given
+// offset, the start of the line holding it being just after, and whether
+// there is one.  Like NextNewline, it does not scan the document.
+func (r *Rope) PrevNewline(offset int) (int, bool) {
+	k := r.root.newlinesBefore(min(max(offset, 0), r.root.length))
+	if k == 0 {
+		return 0, false
+	}
+
+	return r.root.findNewline(k), true
+}
+
+// WriteNumberedLines writes the content of the Rope to w with each line
+// prefixed by its number, in the format of cat -n.  Lines are numbered from
+// startLine+1, so a startLine of 0 numbers them from 1.  As with cat, a final
+// line without a newline is written without one, and the empty line which
+// follows a trailing newline is not written.  The content is streamed from
+// the leaves rather than copied.
+func (r *Rope) WriteNumberedLines(w io.Writer, startLine int) error {
+	buf := bufio.NewWriter(w)
+	line := startLine
+	start := true
+	var err error
+	r.root.walk(func(value string) bool {
+		for value != "" && err == nil {
+			if start {
+				line++
+				_, err = fmt.Fprintf(buf, "%6d\t", line)
+				start = false
+			}
+			i := strings.IndexByte(value, '\n')
+			if i < 0 {
+				_, err = buf.WriteString(value)
+				break
+			}
+			_, err = buf.WriteString(value[:i+1])
+			value = value[i+1:]
+			start = true
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// walkLines calls fn with each line of the Rope, without its newline, until
+// fn returns false.  A line within a single leaf is passed without copying.
+func (r *Rope) walkLines(fn func(line string) bool) {
+	var buf strings.Builder
+	if !r.root.walk(func(value string) bool {
+		for {
+			i := strings.IndexByte(value, '\n')
+			if i < 0 {
+				buf.WriteString(value)
+				return true
+			}
+
+			line := value[:i]
+			if buf.Len() > 0 {
+				buf.WriteString(line)
+				line = buf.String()
+				buf.Reset()
+			}
+			if !fn(line) {
+				return false
+			}
+			value = value[i+1:]
+		}
+	}) {
+		return
+	}
+	fn(buf.String())
+}
+
+// findNewline returns the rune offset of the k-th newline in the node,
+// counting from 1.  The node must contain at least k newlines.
+func (n *node) findNewline(k int) int {
+	if n.value != nil {
+		offset := 0
+		for _, ru := range *n.value {
+			if ru == '\n' {
+				k--
+				if k == 0 {
+					break
+				}
+			}
+			offset++
+		}
+		return offset
+	}
+
+	if k <= n.left.newlines {
+		return n.left.findNewline(k)
+	}
+
+	return n.left.length + n.right.findNewline(k-n.left.newlines)
+}
+
+// lineEnd returns the rune offset of the end of the 0-based line, which is
+// either the position of its terminating newline or the end of the node.
+func (n *node) lineEnd(line int) int {
+	if line < n.newlines {
+		return n.findNewline(line + 1)
+	}
+
+	return n.length
+}
+
+// lineStart returns the rune offset of the first rune of the 0-based line
+func (n *node) lineStart(line int) int {
+	if line == 0 {
+		return 0
+	}
+
+	return n.findNewline(line) + 1
+}
+
+// newlinesBefore returns the number of newlines before the given rune offset
+func (n *node) newlinesBefore(position int) int {
+	count := 0
+	for n.value == nil {
+		if position < n.left.length {
+			n = n.left
+		} else {
+			position -= n.left.length
+			count += n.left.newlines
+			n = n.right
+		}
+	}
+
+	value := *n.value
+	return count + strings.Count(value[:n.findByteOffsets(position)], "\n")
+}
@@ -0,0 +1,391 @@
+package rope
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_InsertLineAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		line     int
+		expected string
+	}{
+		{"empty", "", 0, "\n\tx"},
+		{"single-line", "a", 0, "a\n\tx"},
+		{"first", "a\nb\nc", 0, "a\n\tx\nb\nc"},
+		{"middle", "a\nb\nc", 1, "a\nb\n\tx\nc"},
+		{"last", "a\nb\nc", 2, "a\nb\nc\n\tx"},
+		{"last-trailing-newline", "a\nb\nc\n", 2, "a\nb\nc\n\tx\n"},
+		{"after-trailing-newline", "a\nb\nc\n", 3, "a\nb\nc\n\n\tx"},
+		{"unicode", "🐿🐿\n🐈🐈\n🍩", 1, "🐿🐿\n🐈🐈\n\tx\n🍩"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope(tc.init)
+			if err := r.InsertLineAfter(tc.line, "x", "\t"); err != nil {
+				t.Fatal(err)
+			}
+			if result := r.String(); result != tc.expected {
+				t.Fatalf("InsertLineAfter failed:\nExpected:\n%q\nGot:\n%q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_InsertLineAfter_Large(t *testing.T) {
+	loopTest(t, "InsertLineAfter-Large", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 20)
+		for i := range lines {
+			lines[i] = charSet.generator(stringSize.size / 10)
+		}
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		for _, line := range []int{0, 7, 19} {
+			if err := r.InsertLineAfter(line, "x", "  "); err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines[:line+1], append([]string{"  x"}, lines[line+1:]...)...)
+
+			expected := strings.Join(lines, "\n")
+			if result := r.String(); result != expected {
+				t.Fatalf("InsertLineAfter failed:\nExpected:\n%q\nGot:\n%q", expected, result)
+			}
+		}
+	})
+}
+
+func Test_InsertLineAfter_OutOfBounds(t *testing.T) {
+	r := CreateRope("a\nb")
+	if err := r.InsertLineAfter(-1, "x", ""); err == nil {
+		t.Fatal("Expected error for negative line")
+	}
+	if err := r.InsertLineAfter(2, "x", ""); err == nil {
+		t.Fatal("Expected error for line past the end")
+	}
+	if result := r.String(); result != "a\nb" {
+		t.Fatalf("Rope was modified: %q", result)
+	}
+}
+
+func Test_LineCount(t *testing.T) {
+	tests := []struct {
+		init     string
+		expected int
+	}{
+		{"", 1},
+		{"a", 1},
+		{"\n", 2},
+		{"a\nb", 2},
+		{"a\nb\n", 3},
+		{"🐿\n\n🐿", 3},
+	}
+
+	for _, tc := range tests {
+		if count := CreateRope(tc.init).LineCount(); count != tc.expected {
+			t.Fatalf("Incorrect line count for %q: expected %d, got %d", tc.init, tc.expected, count)
+		}
+	}
+}
+
+func Test_LineNumberWidth(t *testing.T) {
+	tests := []struct {
+		lines    int
+		expected int
+	}{
+		{1, 1},
+		{9, 1},
+		{10, 2},
+		{99, 2},
+		{100, 3},
+		{12345, 5},
+	}
+
+	for _, tc := range tests {
+		r := CreateRope(strings.Repeat("a\n", tc.lines-1))
+		if width := r.LineNumberWidth(); width != tc.expected {
+			t.Fatalf("Incorrect width for %d lines: expected %d, got %d", tc.lines, tc.expected, width)
+		}
+	}
+}
+
+func Test_LineStarts(t *testing.T) {
+	tests := []struct {
+		init     string
+		expected []int
+	}{
+		{"", []int{0}},
+		{"abc", []int{0}},
+		{"abc\n", []int{0, 4}},
+		{"a\nbc\nd", []int{0, 2, 5}},
+		{"a\nbc\nd\n", []int{0, 2, 5, 7}},
+		{"\n\n", []int{0, 1, 2}},
+		{"🐿\n🐈🐈\n", []int{0, 2, 5}},
+	}
+
+	for _, tc := range tests {
+		starts := CreateRope(tc.init).LineStarts()
+		if !reflect.DeepEqual(starts, tc.expected) {
+			t.Fatalf("Incorrect line starts for %q: expected %v, got %v", tc.init, tc.expected, starts)
+		}
+	}
+}
+
+func Test_LineStarts_Large(t *testing.T) {
+	loopTest(t, "LineStarts-Large", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		for _, trailing := range []string{"", "\n"} {
+			lines := make([]string, 30)
+			for i := range lines {
+				lines[i] = charSet.generator(stringSize.size / 10)
+			}
+			init := strings.Join(lines, "\n") + trailing
+			r := CreateRope(init)
+
+			expected := []int{0}
+			offset := 0
+			for _, ru := range init {
+				offset++
+				if ru == '\n' {
+					expected = append(expected, offset)
+				}
+			}
+
+			starts := r.LineStarts()
+			if !reflect.DeepEqual(starts, expected) {
+				t.Fatalf("Incorrect line starts: expected %v, got %v", expected, starts)
+			}
+			if len(starts) != r.LineCount() {
+				t.Fatalf("Incorrect line start count: expected %d, got %d", r.LineCount(), len(starts))
+			}
+			if trailing != "" && starts[len(starts)-1] != utf8.RuneCountInString(init) {
+				t.Fatalf("Final line start is not the end of the document: %d", starts[len(starts)-1])
+			}
+		}
+	})
+}
+
+func Test_WriteNumberedLines(t *testing.T) {
+	tests := []struct {
+		init      string
+		startLine int
+		expected  string
+	}{
+		{"", 0, ""},
+		{"abc", 0, "     1\tabc"},
+		{"abc\n", 0, "     1\tabc\n"},
+		{"a\n\nb\n", 0, "     1\ta\n     2\t\n     3\tb\n"},
+		{"a\nb", 99, "   100\ta\n   101\tb"},
+		{"🐿\n🐈🐈\n", 0, "     1\t🐿\n     2\t🐈🐈\n"},
+	}
+
+	for _, tc := range tests {
+		var buf strings.Builder
+		if err := CreateRope(tc.init).WriteNumberedLines(&buf, tc.startLine); err != nil {
+			t.Fatal(err)
+		}
+		if result := buf.String(); result != tc.expected {
+			t.Fatalf("Incorrect output for %q:\nExpected:\n%q\nGot:\n%q", tc.init, tc.expected, result)
+		}
+	}
+}
+
+func Test_WriteNumberedLines_Large(t *testing.T) {
+	loopTest(t, "WriteNumberedLines", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 30)
+		for i := range lines {
+			lines[i] = charSet.generator(stringSize.size / 10)
+		}
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		var expected strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				expected.WriteString("\n")
+			}
+			expected.WriteString(fmt.Sprintf("%6d\t%s", i+1, line))
+		}
+
+		var buf strings.Builder
+		if err := r.WriteNumberedLines(&buf, 0); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected.String() {
+			t.Fatalf("Incorrect numbered lines")
+		}
+	})
+}
+
+func Test_NextPrevNewline(t *testing.T) {
+	loopTest(t, "NextPrevNewline", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(strings.Map(func(ru rune) rune {
+			if ru == 'a' {
+				return '\n'
+			}
+			return ru
+		}, charSet.generator(stringSize.size)))
+		r := CreateRope(string(runes))
+
+		for offset := -1; offset <= len(runes)+1; offset++ {
+			next, nextOk := -1, false
+			for i := max(offset, 0); i < len(runes); i++ {
+				if runes[i] == '\n' {
+					next, nextOk = i, true
+					break
+				}
+			}
+			prev, prevOk := -1, false
+			for i := min(offset, len(runes)) - 1; i >= 0; i-- {
+				if runes[i] == '\n' {
+					prev, prevOk = i, true
+					break
+				}
+			}
+
+			if n, ok := r.NextNewline(offset); ok != nextOk || ok && n != next {
+				t.Fatalf("Incorrect next newline from %d: expected %d/%t, got %d/%t", offset, next, nextOk, n, ok)
+			}
+			if p, ok := r.PrevNewline(offset); ok != prevOk || ok && p != prev {
+				t.Fatalf("Incorrect previous newline from %d: expected %d/%t, got %d/%t", offset, prev, prevOk, p, ok)
+			}
+		}
+	})
+}
+
+func Test_NextPrevNewline_None(t *testing.T) {
+	for _, init := range []string{"", "abc", "🐿🐿"} {
+		r := CreateRope(init)
+		for offset := 0; offset <= r.Length(); offset++ {
+			if _, ok := r.NextNewline(offset); ok {
+				t.Fatalf("Found next newline in %q", init)
+			}
+			if _, ok := r.PrevNewline(offset); ok {
+				t.Fatalf("Found previous newline in %q", init)
+			}
+		}
+	}
+
+	r := CreateRope("\nab\n")
+	if n, ok := r.NextNewline(0); n != 0 || !ok {
+		t.Fatalf("Incorrect next newline at start: got %d/%t", n, ok)
+	}
+	if p, ok := r.PrevNewline(0); ok {
+		t.Fatalf("Found previous newline before start: got %d", p)
+	}
+	if p, ok := r.PrevNewline(4); p != 3 || !ok {
+		t.Fatalf("Incorrect previous newline at end: got %d/%t", p, ok)
+	}
+	if n, ok := r.NextNewline(4); ok {
+		t.Fatalf("Found next newline after end: got %d", n)
+	}
+}
+
+func Test_CountLinesFunc(t *testing.T) {
+	tests := []struct {
+		init     string
+		nonEmpty int
+		total    int
+	}{
+		{"", 0, 1},
+		{"abc", 1, 1},
+		{"abc\n", 1, 2},
+		{"a\n\nb\n\n", 2, 5},
+		{"🐿\n🐈🐈\n", 2, 3},
+	}
+
+	for _, tc := range tests {
+		r := CreateRope(tc.init)
+		if count := r.CountLinesFunc(func(line string) bool { return line != "" }); count != tc.nonEmpty {
+			t.Fatalf("Incorrect count of non-empty lines in %q: expected %d, got %d", tc.init, tc.nonEmpty, count)
+		}
+		if count := r.CountLinesFunc(func(line string) bool { return true }); count != tc.total {
+			t.Fatalf("Incorrect count of lines in %q: expected %d, got %d", tc.init, tc.total, count)
+		}
+	}
+}
+
+func Test_CountLinesFunc_Large(t *testing.T) {
+	loopTest(t, "CountLinesFunc", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 30)
+		for i := range lines {
+			lines[i] = charSet.generator(i * stringSize.size / 30)
+		}
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		long := 0
+		for _, line := range lines {
+			if stringWidth(line) > 80 {
+				long++
+			}
+		}
+		var seen []string
+		count := r.CountLinesFunc(func(line string) bool {
+			seen = append(seen, line)
+			return stringWidth(line) > 80
+		})
+		if count != long {
+			t.Fatalf("Incorrect count of long lines: expected %d, got %d", long, count)
+		}
+		if !reflect.DeepEqual(seen, lines) {
+			t.Fatalf("Incorrect lines")
+		}
+	})
+}
+
+func Test_ColumnBlock(t *testing.T) {
+	r := CreateRope("abcdefgh\nab\n\n🐈🐑🐿🍩☕🍷\nabcdefghijklmnop")
+	tests := []struct {
+		name                                 string
+		startLine, endLine, startCol, endCol int
+		expected                             []string
+	}{
+		{"all", 0, 4, 2, 5, []string{"cde", "", "", "🐿🍩☕", "cde"}},
+		{"single", 3, 3, 0, 2, []string{"🐈🐑"}},
+		{"past-ends", 0, 1, 1, 100, []string{"bcdefgh", "b"}},
+		{"empty-columns", 0, 2, 3, 3, []string{"", "", ""}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			block, err := r.ColumnBlock(tc.startLine, tc.endLine, tc.startCol, tc.endCol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(block, tc.expected) {
+				t.Fatalf("Incorrect block: expected %q, got %q", tc.expected, block)
+			}
+		})
+	}
+
+	for _, tc := range [][4]int{{-1, 0, 0, 1}, {0, 5, 0, 1}, {2, 1, 0, 1}, {0, 1, -1, 1}, {0, 1, 3, 2}} {
+		if _, err := r.ColumnBlock(tc[0], tc[1], tc[2], tc[3]); err == nil {
+			t.Fatalf("Expected error for %v", tc)
+		}
+	}
+}
+
+func Test_ColumnBlock_Large(t *testing.T) {
+	loopTest(t, "ColumnBlock", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 50)
+		for i := range lines {
+			lines[i] = charSet.generator(i * stringSize.size / 50)
+		}
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		block, err := r.ColumnBlock(10, 40, 5, 25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, text := range block {
+			runes := []rune(lines[10+i])
+			expected := string(runes[min(5, len(runes)):min(25, len(runes))])
+			if text != expected {
+				t.Fatalf("Incorrect text for line %d: expected %q, got %q", 10+i, expected, text)
+			}
+		}
+	})
+}
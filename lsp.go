@@ -0,0 +1,95 @@
+package rope
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// LSPPosition is a position in a document as given by the Language Server
+// Protocol: a 0-based line, and a 0-based offset into that line counted in
+// UTF-16 code units.
+type LSPPosition struct {
+	Line      int
+	Character int
+}
+
+// LSPEdit is a text edit as given by the Language Server Protocol, which
+// replaces the text between Start and End with NewText.
+type LSPEdit struct {
+	Start   LSPPosition
+	End     LSPPosition
+	NewText string
+}
+
+// ApplyLSPEdits applies the edits to the Rope.  As in the Language Server
+// Protocol, the positions of every edit refer to the document before any of
+// them is applied, and edits which insert at the same position are applied
+// in the order given.  A Character beyond the end of its line refers to the
+// end of the line.  Once converted to rune offsets, the edits are made as by
+// ApplyEdits.  If any position is invalid, or any two edits overlap, an error
+// is returned and the Rope is left unchanged.
+func (r *Rope) ApplyLSPEdits(edits []LSPEdit) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	ops := make([]EditOp, len(edits))
+	for i, edit := range edits {
+		start, err := r.root.lspOffset(edit.Start)
+		if err != nil {
+			return err
+		}
+		end, err := r.root.lspOffset(edit.End)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("edit %d starts after it ends", i)
+		}
+		ops[i] = EditOp{start, end, edit.NewText}
+	}
+
+	return r.applyEdits(ops)
+}
+
+// lspOffset returns the rune offset of the LSP position
+func (n *node) lspOffset(position LSPPosition) (int, error) {
+	if position.Line < 0 || position.Line > n.newlines {
+		return 0, fmt.Errorf("line is not within rope bounds")
+	}
+	if position.Character < 0 {
+		return 0, fmt.Errorf("character is not within line bounds")
+	}
+
+	start := n.lineStart(position.Line)
+	end := n.lineEnd(position.Line)
+	offset := start
+	units := 0
+	n.walkRange(start, end, func(value string) bool {
+		for _, ru := range value {
+			if units >= position.Character {
+				return false
+			}
+			units += utf16Length(ru)
+			offset++
+		}
+		return true
+	})
+	if units > position.Character {
+		return 0, fmt.Errorf("character is within a surrogate pair")
+	}
+	return offset, nil
+}
+
+// utf16Length returns the number of UTF-16 code units needed to encode the
+// rune
+func utf16Length(ru rune) int {
+	if ru >= 0x10000 && ru <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,152 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ApplyLSPEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		edits    []LSPEdit
+		expected string
+	}{
+		{
+			"none",
+			"abc",
+			nil,
+			"abc",
+		},
+		{
+			"replace",
+			"hello world",
+			[]LSPEdit{{LSPPosition{0, 6}, LSPPosition{0, 11}, "there"}},
+			"hello there",
+		},
+		{
+			"multiple-lines",
+			"one\ntwo\nthree\n",
+			[]LSPEdit{
+				{LSPPosition{0, 0}, LSPPosition{0, 3}, "1"},
+				{LSPPosition{2, 0}, LSPPosition{2, 5}, "3"},
+				{LSPPosition{1, 1}, LSPPosition{1, 2}, "W"},
+			},
+			"1\ntWo\n3\n",
+		},
+		{
+			"across-lines",
+			"one\ntwo\nthree",
+			[]LSPEdit{{LSPPosition{0, 2}, LSPPosition{2, 1}, "-"}},
+			"on-hree",
+		},
+		{
+			"surrogate-pairs",
+			"🐿a🐿b\n🐈c",
+			[]LSPEdit{
+				{LSPPosition{0, 2}, LSPPosition{0, 3}, "A"},
+				{LSPPosition{0, 5}, LSPPosition{0, 6}, "B"},
+				{LSPPosition{1, 2}, LSPPosition{1, 3}, "C"},
+			},
+			"🐿A🐿B\n🐈C",
+		},
+		{
+			"same-position-inserts",
+			"ac",
+			[]LSPEdit{
+				{LSPPosition{0, 1}, LSPPosition{0, 1}, "b"},
+				{LSPPosition{0, 1}, LSPPosition{0, 1}, "B"},
+			},
+			"abBc",
+		},
+		{
+			"adjacent",
+			"abcd",
+			[]LSPEdit{
+				{LSPPosition{0, 2}, LSPPosition{0, 4}, "D"},
+				{LSPPosition{0, 0}, LSPPosition{0, 2}, "B"},
+			},
+			"BD",
+		},
+		{
+			"past-end-of-line",
+			"ab\ncd",
+			[]LSPEdit{{LSPPosition{0, 10}, LSPPosition{0, 10}, "!"}},
+			"ab!\ncd",
+		},
+		{
+			"end-of-document",
+			"ab\n",
+			[]LSPEdit{{LSPPosition{1, 0}, LSPPosition{1, 0}, "cd"}},
+			"ab\ncd",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope(tc.init)
+			if err := r.ApplyLSPEdits(tc.edits); err != nil {
+				t.Fatal(err)
+			}
+			if result := r.String(); result != tc.expected {
+				t.Fatalf("ApplyLSPEdits failed:\nExpected:\n%q\nGot:\n%q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_ApplyLSPEdits_Large(t *testing.T) {
+	loopTest(t, "ApplyLSPEdits-Large", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 20)
+		for i := range lines {
+			lines[i] = charSet.generator(stringSize.size / 10)
+		}
+		r := CreateRope(strings.Join(lines, "\n"))
+
+		edits := []LSPEdit{}
+		for i := range lines {
+			if i%3 == 0 {
+				edits = append(edits, LSPEdit{LSPPosition{i, 0}, LSPPosition{i, 1 << 20}, "x"})
+				lines[i] = "x"
+			}
+		}
+		if err := r.ApplyLSPEdits(edits); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join(lines, "\n")
+		if result := r.String(); result != expected {
+			t.Fatalf("ApplyLSPEdits failed:\nExpected:\n%q\nGot:\n%q", expected, result)
+		}
+	})
+}
+
+func Test_ApplyLSPEdits_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []LSPEdit
+	}{
+		{"negative-line", []LSPEdit{{LSPPosition{-1, 0}, LSPPosition{0, 0}, "x"}}},
+		{"line-past-end", []LSPEdit{{LSPPosition{0, 0}, LSPPosition{2, 0}, "x"}}},
+		{"negative-character", []LSPEdit{{LSPPosition{0, -1}, LSPPosition{0, 0}, "x"}}},
+		{"mid-surrogate", []LSPEdit{{LSPPosition{0, 1}, LSPPosition{0, 2}, "x"}}},
+		{"reversed", []LSPEdit{{LSPPosition{0, 3}, LSPPosition{0, 2}, "x"}}},
+		{"overlap", []LSPEdit{
+			{LSPPosition{0, 0}, LSPPosition{0, 0}, "ok"},
+			{LSPPosition{0, 2}, LSPPosition{0, 4}, "x"},
+			{LSPPosition{0, 3}, LSPPosition{1, 0}, "y"},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope("🐿abc\ndef")
+			if err := r.ApplyLSPEdits(tc.edits); err == nil {
+				t.Fatal("Expected error")
+			}
+			if result := r.String(); result != "🐿abc\ndef" {
+				t.Fatalf("Rope was modified: %q", result)
+			}
+		})
+	}
+}
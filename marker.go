@@ -0,0 +1,87 @@
+package rope
+
+import (
+	"fmt"
+)
+
+// Marker is a position in a Rope which tracks the content around it as the
+// Rope is edited.  Text inserted or removed before a Marker moves it, and
+// text inserted at its position is placed after it.  A Marker inside a range
+// which is removed or replaced is invalidated, as the content it marked no
+// longer exists.  Markers remain registered with their Rope until passed to
+// Unmark.
+type Marker struct {
+	r        *Rope
+	position int
+	valid    bool
+}
+
+// Position returns the current rune offset of the Marker, and whether the
+// Marker is still valid
+func (m *Marker) Position() (int, bool) {
+	return m.position, m.valid
+}
+
+// Between returns the content between the current positions of two Markers
+// of the Rope, in whichever order they fall.  An error is returned if either
+// Marker has been invalidated or belongs to another Rope.
+func (r *Rope) Between(a, b *Marker) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("Nil pointer receiver")
+	}
+
+	for _, m := range []*Marker{a, b} {
+		if m == nil || m.r != r {
+			return "", fmt.Errorf("marker does not belong to rope")
+		}
+		if !m.valid {
+			return "", fmt.Errorf("marker has been invalidated")
+		}
+	}
+
+	start, end := a.position, b.position
+	if start > end {
+		start, end = end, start
+	}
+	return r.root.substring(start, end), nil
+}
+
+// Mark creates a Marker at the given rune offset
+func (r *Rope) Mark(position int) (*Marker, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if position < 0 || position > r.root.length {
+		return nil, fmt.Errorf("position %d: %w", position, ErrIndexOutOfRange)
+	}
+
+	m := &Marker{r: r, position: position, valid: true}
+	r.markers = append(r.markers, m)
+	return m, nil
+}
+
+// Unmark stops the Rope from tracking the Marker, and invalidates it
+func (r *Rope) Unmark(m *Marker) {
+	for i, marker := range r.markers {
+		if marker == m {
+			r.markers = append(r.markers[:i], r.markers[i+1:]...)
+			m.valid = false
+			return
+		}
+	}
+}
+
+// moveMarkers updates the Markers for an edit which replaced the runes
+// between start and end with inserted runes
+func (r *Rope) moveMarkers(start, end, inserted int) {
+	for _, m := range r.markers {
+		switch {
+		case m.position <= start:
+		case m.position >= end:
+			m.position += inserted - (end - start)
+		default:
+			m.valid = false
+		}
+	}
+}
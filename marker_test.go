@@ -0,0 +1,85 @@
+package rope
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Marker_Between(t *testing.T) {
+	r := CreateRope("hello, world")
+	anchor, _ := r.Mark(7)
+	head, _ := r.Mark(12)
+
+	if s, err := r.Between(head, anchor); err != nil || s != "world" {
+		t.Fatalf("Incorrect selection: got %q, %v", s, err)
+	}
+
+	r.Insert(0, "¡")
+	r.Insert(7, "big ")
+	if s, err := r.Between(anchor, head); err != nil || s != "world" {
+		t.Fatalf("Incorrect selection after inserts: got %q, %v", s, err)
+	}
+	if position, valid := anchor.Position(); position != 12 || !valid {
+		t.Fatalf("Incorrect anchor: got %d, %t", position, valid)
+	}
+
+	r.Insert(17, "!")
+	if s, _ := r.Between(anchor, head); s != "world" {
+		t.Fatalf("Text inserted at marker was included: got %q", s)
+	}
+
+	r.Remove(1, 12)
+	if s, err := r.Between(anchor, head); err != nil || s != "world" {
+		t.Fatalf("Incorrect selection after remove: got %q, %v", s, err)
+	}
+
+	r.Alter(0, 2, "X")
+	if _, valid := anchor.Position(); valid {
+		t.Fatalf("Anchor should be invalid")
+	}
+	if _, valid := head.Position(); !valid {
+		t.Fatalf("Head should be valid")
+	}
+	if _, err := r.Between(anchor, head); err == nil {
+		t.Fatalf("Expected error for invalidated marker")
+	}
+}
+
+func Test_Marker_ForeignAndUnmarked(t *testing.T) {
+	r := CreateRope("abc")
+	other := CreateRope("abc")
+	a, _ := r.Mark(0)
+	b, _ := other.Mark(3)
+
+	if _, err := r.Between(a, b); err == nil {
+		t.Fatalf("Expected error for marker of another rope")
+	}
+
+	if _, err := r.Mark(4); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("Expected error for out of bounds mark")
+	}
+
+	r.Unmark(a)
+	if _, valid := a.Position(); valid {
+		t.Fatalf("Unmarked marker should be invalid")
+	}
+	if len(r.markers) != 0 {
+		t.Fatalf("Unmarked marker is still tracked")
+	}
+}
+
+func Test_Marker_LargeRope(t *testing.T) {
+	loopTest(t, "Between", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		runes := []rune(init)
+		a, _ := r.Mark(len(runes) / 3)
+		b, _ := r.Mark(len(runes) - len(runes)/3)
+		expected := string(runes[len(runes)/3 : len(runes)-len(runes)/3])
+
+		r.Insert(0, init)
+		if s, err := r.Between(b, a); err != nil || s != expected {
+			t.Fatalf("Incorrect content between markers: %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package rope
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// Conflict is a region in which both sides of a Merge changed the same lines
+// of the base in different ways.  Each range is of runes: Merged is the span
+// of the merged Rope which holds the lines of ours, and Base, Ours and Theirs
+// are the spans of the lines in each of the merged Ropes.
+type Conflict struct {
+	Merged Range
+	Base   Range
+	Ours   Range
+	Theirs Range
+}
+
+// Merge combines the changes which ours and theirs each made to base, as a
+// version control system merges two branches.  The lines of each side are
+// compared with those of base, as by Diff, and a region of lines which only
+// one side changed, or which both changed alike, takes that change.  A
+// region which both sides changed differently is a Conflict; the merged Rope
+// holds the lines of ours there, and the Conflicts give where, in order.  The
+// merged Rope has the options of ours, and ErrTooLarge is returned if it
+// would exceed their maximum size.
+func Merge(base, ours, theirs *Rope) (*Rope, []Conflict, error) {
+	for _, arg := range []struct {
+		name string
+		r    *Rope
+	}{{"base", base}, {"ours", ours}, {"theirs", theirs}} {
+		if arg.r == nil {
+			return nil, nil, fmt.Errorf("nil %s rope", arg.name)
+		}
+	}
+
+	a := splitLines(base.String())
+	x := splitLines(ours.String())
+	y := splitLines(theirs.String())
+
+	// Lines of base kept by both sides divide the regions
+	inOurs := make([]int, len(a))
+	inTheirs := make([]int, len(a))
+	for i := range a {
+		inOurs[i], inTheirs[i] = -1, -1
+	}
+	for _, match := range matchLines(a, x) {
+		inOurs[match[0]] = match[1]
+	}
+	for _, match := range matchLines(a, y) {
+		inTheirs[match[0]] = match[1]
+	}
+
+	aOffsets, xOffsets, yOffsets := lineOffsets(a), lineOffsets(x), lineOffsets(y)
+
+	var b Builder
+	var conflicts []Conflict
+	length := 0
+	write := func(lines []string) {
+		for _, line := range lines {
+			b.WriteString(line)
+			length += utf8.RuneCountInString(line)
+		}
+	}
+
+	i, j, k := 0, 0, 0
+	for stable := 0; stable <= len(a); stable++ {
+		if stable < len(a) && (inOurs[stable] < 0 || inTheirs[stable] < 0) {
+			continue
+		}
+
+		nextJ, nextK := len(x), len(y)
+		if stable < len(a) {
+			nextJ, nextK = inOurs[stable], inTheirs[stable]
+		}
+		original, mine, other := a[i:stable], x[j:nextJ], y[k:nextK]
+		switch {
+		case slices.Equal(mine, original):
+			write(other)
+		case slices.Equal(other, original), slices.Equal(mine, other):
+			write(mine)
+		default:
+			start := length
+			write(mine)
+			conflicts = append(conflicts, Conflict{
+				Merged: Range{start, length},
+				Base:   Range{aOffsets[i], aOffsets[stable]},
+				Ours:   Range{xOffsets[j], xOffsets[nextJ]},
+				Theirs: Range{yOffsets[k], yOffsets[nextK]},
+			})
+		}
+
+		if stable < len(a) {
+			write(a[stable : stable+1])
+		}
+		i, j, k = stable+1, nextJ+1, nextK+1
+	}
+
+	merged := b.Rope()
+	merged.options = ours.options
+	if merged.options.MaxBytes != 0 && merged.root.byteLength > merged.options.MaxBytes {
+		return nil, nil, ErrTooLarge
+	}
+	return merged, conflicts, nil
+}
+
+// lineOffsets returns the rune offset of the start of each line, and of the
+// end of the last
+func lineOffsets(lines []string) []int {
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + utf8.RuneCountInString(line)
+	}
+	return offsets
+}
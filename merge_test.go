@@ -0,0 +1,116 @@
+package rope
+
+import (
+	"testing"
+)
+
+func Test_Merge(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		expected  string
+		conflicts []Conflict
+	}{
+		{"Unchanged", base, base, base, nil},
+		{"Ours only", "a\nB\nc\nd\ne\n", base, "a\nB\nc\nd\ne\n", nil},
+		{"Theirs only", base, "a\nb\nc\n🐿\ne\n", "a\nb\nc\n🐿\ne\n", nil},
+		{"Both apart", "x\na\nb\nc\nd\ne\n", "a\nb\nc\nd\n", "x\na\nb\nc\nd\n", nil},
+		{"Both alike", "a\nb\nC\nd\ne\n", "a\nb\nC\nd\ne\n", "a\nb\nC\nd\ne\n", nil},
+		{"No newline", "a\nb\nc\nd\ne\nf", "A\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\nf", nil},
+		{
+			"Conflict",
+			"a\nb\nours\nd\ne\n",
+			"a\nb\n🐿\ntheirs\nd\ne\n",
+			"a\nb\nours\nd\ne\n",
+			[]Conflict{{Merged: Range{4, 9}, Base: Range{4, 6}, Ours: Range{4, 9}, Theirs: Range{4, 13}}},
+		},
+		{
+			"Conflicts",
+			"1\nb\nc\nd\n2\n",
+			"3\nb\nc\nd\n4\n",
+			"1\nb\nc\nd\n2\n",
+			[]Conflict{
+				{Merged: Range{0, 2}, Base: Range{0, 2}, Ours: Range{0, 2}, Theirs: Range{0, 2}},
+				{Merged: Range{8, 10}, Base: Range{8, 10}, Ours: Range{8, 10}, Theirs: Range{8, 10}},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts, err := Merge(CreateRope(base), CreateRope(tc.ours), CreateRope(tc.theirs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged.String() != tc.expected {
+				t.Fatalf("Incorrect merge: expected %q, got %q", tc.expected, merged.String())
+			}
+			if len(conflicts) != len(tc.conflicts) {
+				t.Fatalf("Incorrect conflicts: expected %v, got %v", tc.conflicts, conflicts)
+			}
+			for i, c := range conflicts {
+				if c != tc.conflicts[i] {
+					t.Fatalf("Incorrect conflict %d: expected %v, got %v", i, tc.conflicts[i], c)
+				}
+			}
+		})
+	}
+}
+
+func Test_Merge_Large(t *testing.T) {
+	loopTest(t, "Merge", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		var lines []string
+		for i := 0; i < stringSize.size; i++ {
+			lines = append(lines, charSet.generator(10)+"\n")
+		}
+		base := JoinStrings(lines, "")
+		ours, theirs := base.Snapshot(), base.Snapshot()
+
+		// Edits to lines far apart merge cleanly
+		ours.Insert(11, "ours\n")
+		theirs.Insert((stringSize.size-2)*11, "theirs\n")
+
+		merged, conflicts, err := Merge(base, ours, theirs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conflicts) != 0 {
+			t.Fatalf("Unexpected conflicts: %v", conflicts)
+		}
+		ours.Insert((stringSize.size-2)*11+5, "theirs\n")
+		if merged.String() != ours.String() {
+			t.Fatal("Incorrect merge")
+		}
+		if err := merged.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_Merge_TooLarge(t *testing.T) {
+	base := CreateRope("a\n")
+	ours := CreateRopeWithOptions("a\nb\n", Options{MaxBytes: 4})
+	theirs := CreateRope("c\na\n")
+	if _, _, err := Merge(base, ours, theirs); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func Test_Merge_Nil(t *testing.T) {
+	r := CreateRope("a\n")
+	tests := []struct {
+		base, ours, theirs *Rope
+		expected           string
+	}{
+		{nil, r, r, "nil base rope"},
+		{r, nil, r, "nil ours rope"},
+		{r, r, nil, "nil theirs rope"},
+	}
+	for _, tc := range tests {
+		if _, _, err := Merge(tc.base, tc.ours, tc.theirs); err == nil || err.Error() != tc.expected {
+			t.Fatalf("Expected %q, got %v", tc.expected, err)
+		}
+	}
+}
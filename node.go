@@ -0,0 +1,748 @@
+package rope
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	splitLength = 512
+	joinLength  = 256
+	fillLength  = (splitLength + joinLength) >> 1
+
+	// bulkLength is the rune length above which an inserted value is built
+	// into its own balanced subtree instead of being merged into a leaf
+	bulkLength = 4 * splitLength
+
+	rebalanceRatio = 1.2
+)
+
+// node is a single element of a Rope's tree.  A leaf holds a fragment of the
+// string in value; an internal node has a nil value, and the string it
+// represents is the concatenation of its left and right children.
+//
+// Nodes may be shared between several trees, for example after taking a
+// prefix of a Rope.  A shared node must never be modified in place; callers
+// which intend to edit a node must first obtain a private copy of it through
+// mutable.
+type node struct {
+	right      *node
+	left       *node
+	value      *string
+	length     int
+	byteLength int
+	newlines   int
+	depth      int
+
+	// shared is set once the node is reachable from more than one tree.  It
+	// is atomic, as goroutines editing Versions which hold the node may each
+	// set it at once.
+	shared atomic.Bool
+
+	// edits counts the mutations which have touched the runes under the
+	// node, for EditHeat.  An internal node holds the sum of its children.
+	edits int
+}
+
+// nodePool holds nodes which are no longer part of any tree, so that they can
+// be reused when building a new one.  A nil pool allocates every node.
+type nodePool []*node
+
+func newNode(value string) *node {
+	n := &node{value: &value, length: utf8.RuneCountInString(value), byteLength: len(value), newlines: strings.Count(value, "\n")}
+	n.adjust()
+	return n
+}
+
+// build creates a balanced tree holding the value, cut into leaves of about
+// leafLength runes each
+func build(pool *nodePool, value string, leafLength int) *node {
+	length := utf8.RuneCountInString(value)
+	leaves := make([]*node, 0, length/leafLength+1)
+	return merge(pool, appendLeaves(pool, leaves, value, length, leafLength))
+}
+
+func (n *node) adjust() {
+	if n.value != nil {
+		if n.length > splitLength {
+			divide := n.length >> 1
+			offset := n.findByteOffsets(divide)
+			n.left = newNode((*n.value)[:offset])
+			n.right = newNode((*n.value)[offset:])
+			spreadEdits([]*node{n.left, n.right}, n.edits)
+			n.value = nil
+			n.recount()
+		}
+	} else {
+		if n.length < joinLength {
+			n.join()
+		}
+	}
+}
+
+// alter replaces the runes between start and end with the value.  If removed
+// is not nil, the replaced runes are written to it.
+func (n *node) alter(start, end int, value string, removed *strings.Builder) error {
+	valueLength := utf8.RuneCountInString(value)
+	valueByteLength := len(value)
+
+	if n.value != nil {
+		var buf bytes.Buffer
+		byteStart := n.findByteOffsets(start)
+		byteEnd := n.findByteOffsets(end)
+		if removed != nil {
+			removed.WriteString((*n.value)[byteStart:byteEnd])
+		}
+		buf.Grow(len(*n.value) - byteEnd + byteStart + valueByteLength)
+		buf.WriteString((*n.value)[0:byteStart])
+		buf.WriteString(value)
+		buf.WriteString((*n.value)[byteEnd:])
+		s := buf.String()
+		n.value = &s
+		n.byteLength -= byteEnd - byteStart - valueByteLength
+		n.length -= end - start - valueLength
+		n.newlines = strings.Count(s, "\n")
+		n.edits++
+	} else {
+		leftLength := n.left.length
+		leftStart := min(start, leftLength)
+		rightLength := n.right.length
+		rightEnd := max(0, min(end-leftLength, rightLength))
+
+		// A range which ends within the left child takes the whole value, or
+		// the rest of that child would follow part of it.  Otherwise the left
+		// child takes as much of the value as the runes it loses.
+		valueCutoff := valueByteLength
+		if end >= leftLength {
+			valueCutoff = findByteOffset(value, min(valueLength, leftLength-leftStart))
+		}
+
+		if leftStart < leftLength {
+			leftEnd := min(end, leftLength)
+			n.left = n.left.mutable()
+			n.left.alter(leftStart, leftEnd, value[:valueCutoff], removed)
+		}
+		if rightEnd > 0 || valueCutoff < valueByteLength {
+			rightStart := max(0, min(start-leftLength, rightLength))
+			n.right = n.right.mutable()
+			n.right.alter(rightStart, rightEnd, value[valueCutoff:], removed)
+		}
+		n.recount()
+	}
+
+	n.adjust()
+	return nil
+}
+
+// balance rebuilds the highest node along the deepest path of the tree
+// which is deeper than its length warrants, so that it is balanced again
+func (n *node) balance() {
+	if n.value != nil {
+		return
+	}
+
+	if n.depth > maxDepth(n.length) {
+		n.assign(merge(nil, n.leaves(nil, false)))
+		return
+	}
+
+	if n.left.depth > n.right.depth {
+		n.left = n.left.mutable()
+		n.left.balance()
+	} else {
+		n.right = n.right.mutable()
+		n.right.balance()
+	}
+	n.recount()
+}
+
+// byteOffset returns the byte offset of the rune at the given position
+func (n *node) byteOffset(position int) int {
+	offset := 0
+	for n.value == nil {
+		if position < n.left.length {
+			n = n.left
+		} else {
+			position -= n.left.length
+			offset += n.left.byteLength
+			n = n.right
+		}
+	}
+	return offset + n.findByteOffsets(position)
+}
+
+// assign copies every field of m but shared into the node.  The flag is left
+// alone, as another goroutine may be setting it on m.
+func (n *node) assign(m *node) {
+	n.right = m.right
+	n.left = m.left
+	n.value = m.value
+	n.length = m.length
+	n.byteLength = m.byteLength
+	n.newlines = m.newlines
+	n.depth = m.depth
+	n.edits = m.edits
+}
+
+// clone returns an unshared copy of the node.  The children of the node are
+// now reachable from two parents, so they are marked as shared.
+func (n *node) clone() *node {
+	c := &node{}
+	c.assign(n)
+	if n.value == nil {
+		n.left.shared.Store(true)
+		n.right.shared.Store(true)
+	}
+	return c
+}
+
+func (n *node) findByteOffsets(position int) int {
+	offset := 0
+
+	// Decode rather than converting to runes, so that an invalid byte counts
+	// as the single rune that utf8.RuneCountInString takes it to be.
+	for i := 0; i < position; i++ {
+		_, size := utf8.DecodeRuneInString((*n.value)[offset:])
+		offset += size
+	}
+
+	return offset
+}
+
+func (n *node) insert(position int, value string) error {
+	if n.value != nil {
+		valueLength := utf8.RuneCountInString(value)
+		if valueLength > bulkLength {
+			n.insertBulk(position, value, valueLength)
+			return nil
+		}
+
+		var buf bytes.Buffer
+		offset := n.findByteOffsets(position)
+		valueBytesLength := len(value)
+		buf.Grow(n.byteLength + valueBytesLength)
+		buf.WriteString((*n.value)[0:offset])
+		buf.WriteString(value)
+		buf.WriteString((*n.value)[offset:])
+		s := buf.String()
+		n.value = &s
+		n.byteLength += valueBytesLength
+		n.length += valueLength
+		n.newlines = strings.Count(s, "\n")
+		n.edits++
+	} else {
+		leftLength := n.left.length
+		if position < leftLength {
+			n.left = n.left.mutable()
+			n.left.insert(position, value)
+		} else {
+			n.right = n.right.mutable()
+			n.right.insert(position-leftLength, value)
+		}
+		n.recount()
+	}
+	n.adjust()
+	return nil
+}
+
+// insertBulk splices a large value into a leaf.  Rather than concatenating
+// the value into the leaf and halving the result over and over, the value is
+// cut into leaves directly and assembled into a balanced subtree along with
+// the leaf's original prefix and suffix.
+func (n *node) insertBulk(position int, value string, valueLength int) {
+	offset := n.findByteOffsets(position)
+	leaves := make([]*node, 0, valueLength/fillLength+3)
+	if offset > 0 {
+		leaves = append(leaves, newNode((*n.value)[:offset]))
+	}
+	leaves = appendLeaves(nil, leaves, value, valueLength, fillLength)
+	if offset < len(*n.value) {
+		leaves = append(leaves, newNode((*n.value)[offset:]))
+	}
+	spreadEdits(leaves, n.edits+1)
+	n.assign(merge(nil, leaves))
+}
+
+func (n *node) join() {
+	c := n.left.byteLength + n.right.byteLength
+	var buf bytes.Buffer
+	buf.Grow(c)
+	n.left.writeTo(&buf)
+	n.right.writeTo(&buf)
+	s := buf.String()
+	n.value = &s
+	n.edits = n.left.edits + n.right.edits
+	n.left = nil
+	n.right = nil
+	n.depth = 0
+}
+
+// leaves appends the leaves of the node to the slice, in order.  Leaves
+// reached through a shared node are marked as shared, because they remain
+// reachable through that node after being placed into a new tree.
+func (n *node) leaves(leaves []*node, shared bool) []*node {
+	shared = shared || n.shared.Load()
+	if n.value != nil {
+		if shared {
+			n.shared.Store(true)
+		}
+		return append(leaves, n)
+	}
+
+	leaves = n.left.leaves(leaves, shared)
+	return n.right.leaves(leaves, shared)
+}
+
+func (n *node) locate(position int) (*node, int) {
+	if n.value != nil {
+		return n, position
+	}
+
+	leftLength := n.left.length
+	if position < leftLength {
+		return n.left.locate(position)
+	}
+
+	return n.right.locate(position - leftLength)
+}
+
+// locateByte returns the leaf holding the byte at the given offset, and the
+// offset of that byte within the leaf
+func (n *node) locateByte(offset int) (*node, int) {
+	for n.value == nil {
+		if offset < n.left.byteLength {
+			n = n.left
+		} else {
+			offset -= n.left.byteLength
+			n = n.right
+		}
+	}
+	return n, offset
+}
+
+// mutable returns a node which may be modified in place: the node itself if
+// it is not shared, or else a private copy of it.
+func (n *node) mutable() *node {
+	if n.shared.Load() {
+		return n.clone()
+	}
+	return n
+}
+
+func (n *node) rebalance() {
+	if n.value == nil {
+		leftLength := n.left.length
+		rightLength := n.right.length
+
+		if float32(leftLength)/float32(rightLength) > rebalanceRatio ||
+			float32(rightLength)/float32(leftLength) > rebalanceRatio {
+			n.rebuild()
+		} else {
+			n.left = n.left.mutable()
+			n.left.rebalance()
+			n.right = n.right.mutable()
+			n.right.rebalance()
+		}
+	}
+}
+
+func (n *node) rebuild() {
+	if n.value == nil {
+		n.join()
+		n.adjust()
+	}
+}
+
+// recount updates an internal node's cached counts from its children
+func (n *node) recount() {
+	n.length = n.left.length + n.right.length
+	n.byteLength = n.left.byteLength + n.right.byteLength
+	n.newlines = n.left.newlines + n.right.newlines
+	n.depth = 1 + max(n.left.depth, n.right.depth)
+	n.edits = n.left.edits + n.right.edits
+}
+
+// recycle appends the nodes of the tree which are not shared with any other
+// tree to the pool.  The tree must not be used afterwards.
+func (n *node) recycle(pool nodePool) nodePool {
+	if n.shared.Load() {
+		return pool
+	}
+
+	pool = append(pool, n)
+	if n.value == nil {
+		pool = n.left.recycle(pool)
+		pool = n.right.recycle(pool)
+	}
+	return pool
+}
+
+func (n *node) remove(start, end int) error {
+	if n.value != nil {
+		var buf bytes.Buffer
+		byteStart := n.findByteOffsets(start)
+		byteEnd := n.findByteOffsets(end)
+		buf.Grow(len(*n.value) - byteEnd + byteStart)
+		buf.WriteString((*n.value)[0:byteStart])
+		buf.WriteString((*n.value)[byteEnd:])
+		s := buf.String()
+		n.value = &s
+		n.byteLength -= byteEnd - byteStart
+		n.length -= end - start
+		n.newlines = strings.Count(s, "\n")
+		n.edits++
+	} else {
+		leftLength := n.left.length
+		leftStart := min(start, leftLength)
+		rightLength := n.right.length
+		rightEnd := max(0, min(end-leftLength, rightLength))
+		if leftStart < leftLength {
+			leftEnd := min(end, leftLength)
+			n.left = n.left.mutable()
+			n.left.remove(leftStart, leftEnd)
+		}
+		if rightEnd > 0 {
+			rightStart := max(0, min(start-leftLength, rightLength))
+			n.right = n.right.mutable()
+			n.right.remove(rightStart, rightEnd)
+		}
+		n.recount()
+	}
+
+	n.adjust()
+	return nil
+}
+
+// runeAt returns the rune at the given position, which must be within the
+// node
+func (n *node) runeAt(position int) rune {
+	leaf, offset := n.locate(position)
+	ru, _ := utf8.DecodeRuneInString((*leaf.value)[leaf.findByteOffsets(offset):])
+	return ru
+}
+
+// runeOffset returns the rune offset of the first rune which begins at or
+// after the given byte offset
+func (n *node) runeOffset(byteOffset int) int {
+	if n.value != nil {
+		runes := 0
+		for i := range *n.value {
+			if i >= byteOffset {
+				return runes
+			}
+			runes++
+		}
+		return n.length
+	}
+
+	if byteOffset < n.left.byteLength {
+		return n.left.runeOffset(byteOffset)
+	}
+
+	return n.left.length + n.right.runeOffset(byteOffset-n.left.byteLength)
+}
+
+// share marks the node and every node under it as shared
+func (n *node) share() {
+	n.shared.Store(true)
+	if n.value == nil {
+		n.left.share()
+		n.right.share()
+	}
+}
+
+// slice returns a node representing the runes between start and end.  Any
+// subtree which lies entirely within the range is shared with the result
+// rather than copied, as is the string data of the leaves at either end.
+func (n *node) slice(start, end int) *node {
+	if start == 0 && end == n.length {
+		n.shared.Store(true)
+		return n
+	}
+
+	if n.value != nil {
+		byteStart := n.findByteOffsets(start)
+		byteEnd := n.findByteOffsets(end)
+		s := (*n.value)[byteStart:byteEnd]
+		edits := n.edits * (end - start) / n.length
+		return &node{value: &s, length: end - start, byteLength: byteEnd - byteStart, newlines: strings.Count(s, "\n"), edits: edits}
+	}
+
+	leftLength := n.left.length
+	if end <= leftLength {
+		return n.left.slice(start, end)
+	}
+	if start >= leftLength {
+		return n.right.slice(start-leftLength, end-leftLength)
+	}
+
+	return concat(n.left.slice(start, leftLength), n.right.slice(0, end-leftLength))
+}
+
+// substring returns the runes between start and end as a string.  A range
+// within a single leaf is returned without copying, and one across leaves is
+// copied into a buffer sized from the byte offsets of its ends.
+func (n *node) substring(start, end int) string {
+	var first string
+	var buf strings.Builder
+	n.walkRange(start, end, func(value string) bool {
+		if buf.Len() == 0 && first == "" {
+			first = value
+			return true
+		}
+		if buf.Len() == 0 {
+			buf.Grow(n.byteOffset(end) - n.byteOffset(start))
+			buf.WriteString(first)
+		}
+		buf.WriteString(value)
+		return true
+	})
+	if buf.Len() == 0 {
+		return first
+	}
+	return buf.String()
+}
+
+// truncate returns a node holding the first length runes of this one, which
+// may be the node itself, modified in place unless it is shared.  The
+// subtrees after the cut are dropped rather than visited.  length must be
+// greater than 0.
+func (n *node) truncate(length int) *node {
+	if length == n.length {
+		return n
+	}
+
+	if n.value != nil {
+		offset := n.findByteOffsets(length)
+		s := (*n.value)[:offset]
+		edits := n.edits * length / n.length
+		n = n.mutable()
+		n.value = &s
+		n.length = length
+		n.byteLength = offset
+		n.newlines = strings.Count(s, "\n")
+		n.edits = edits
+		return n
+	}
+
+	if length <= n.left.length {
+		// The left child is reachable from wherever this node is, so is
+		// marked as shared if this node is, as clone would
+		if n.shared.Load() {
+			n.left.shared.Store(true)
+		}
+		return n.left.truncate(length)
+	}
+
+	n = n.mutable()
+	n.right = n.right.truncate(length - n.left.length)
+	n.recount()
+	n.adjust()
+	return n
+}
+
+// unbalanced reports whether any node along the deepest path of the tree is
+// deeper than its length warrants
+func (n *node) unbalanced() bool {
+	for n.value == nil {
+		if n.depth > maxDepth(n.length) {
+			return true
+		}
+		if n.left.depth > n.right.depth {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return false
+}
+
+// walk calls fn with the value of each leaf in order, stopping early if fn
+// returns false.  It reports whether every leaf was visited.
+func (n *node) walk(fn func(value string) bool) bool {
+	if n.value != nil {
+		return fn(*n.value)
+	}
+
+	return n.left.walk(fn) && n.right.walk(fn)
+}
+
+// walkBackward calls fn with the value of each leaf under the node, from
+// last to first, until fn returns false.  It reports whether every leaf was
+// visited.
+func (n *node) walkBackward(fn func(value string) bool) bool {
+	if n.value != nil {
+		return fn(*n.value)
+	}
+
+	return n.right.walkBackward(fn) && n.left.walkBackward(fn)
+}
+
+// walkRange calls fn with the parts of the leaves' values which lie between
+// the rune offsets start and end, in order, stopping early if fn returns
+// false.  It reports whether every part was visited.
+func (n *node) walkRange(start, end int, fn func(value string) bool) bool {
+	if start >= end {
+		return true
+	}
+
+	if n.value != nil {
+		if start == 0 && end == n.length {
+			return fn(*n.value)
+		}
+		return fn((*n.value)[n.findByteOffsets(start):n.findByteOffsets(end)])
+	}
+
+	leftLength := n.left.length
+	if start < leftLength && !n.left.walkRange(start, min(end, leftLength), fn) {
+		return false
+	}
+	if end > leftLength {
+		return n.right.walkRange(max(0, start-leftLength), end-leftLength, fn)
+	}
+	return true
+}
+
+func (n *node) writeShape(buf *bytes.Buffer) {
+	if n.value != nil {
+		buf.WriteString(strconv.Itoa(n.length))
+		return
+	}
+
+	buf.WriteByte('(')
+	n.left.writeShape(buf)
+	buf.WriteByte(' ')
+	n.right.writeShape(buf)
+	buf.WriteByte(')')
+}
+
+func (n *node) writeTo(w io.Writer) (int, error) {
+	if n.value != nil {
+		copied, err := io.WriteString(w, *n.value)
+		if copied != len(*n.value) && err == nil {
+			err = io.ErrShortWrite
+		}
+		return copied, err
+	}
+
+	var err error
+	var l, m int
+
+	l, err = n.left.writeTo(w)
+	if err != nil {
+		return l, err
+	}
+
+	m, err = n.right.writeTo(w)
+
+	return int(l + m), err
+}
+
+// appendLeaves cuts the value into leaves of about leafLength runes each and
+// appends them to the provided slice.  The runes are spread evenly so that
+// the final leaf is not left undersized.  An empty value produces a single
+// empty leaf.
+func appendLeaves(pool *nodePool, leaves []*node, value string, valueLength, leafLength int) []*node {
+	count := max(1, (valueLength+leafLength-1)/leafLength)
+	start := 0
+	for i := 0; i < count; i++ {
+		runes := valueLength*(i+1)/count - valueLength*i/count
+		end := start
+		for j := 0; j < runes; j++ {
+			_, n := utf8.DecodeRuneInString(value[end:])
+			end += n
+		}
+		s := value[start:end]
+		leaf := pool.get()
+		leaf.value = &s
+		leaf.length = runes
+		leaf.byteLength = end - start
+		leaf.newlines = strings.Count(s, "\n")
+		leaves = append(leaves, leaf)
+		start = end
+	}
+	return leaves
+}
+
+// concat joins two nodes under a new parent.  The nodes are incorporated
+// as-is, so callers must ensure that they are marked as shared if they are
+// also reachable from elsewhere.
+func concat(left, right *node) *node {
+	if left.length == 0 {
+		return right
+	}
+	if right.length == 0 {
+		return left
+	}
+
+	n := &node{right: right, left: left}
+	n.recount()
+	n.adjust()
+	return n
+}
+
+func findByteOffset(s string, position int) int {
+	offset := 0
+	for i := 0; i < position; i++ {
+		r, n := utf8.DecodeRuneInString(s[offset:])
+		if r == utf8.RuneError {
+			return -1
+		}
+		offset += n
+	}
+	return offset
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// maxDepth returns the depth beyond which a node of the given length is
+// considered unbalanced: twice the depth of a balanced tree of minimal
+// leaves, with some slack so that small trees are not rebuilt constantly.
+func maxDepth(length int) int {
+	return 2*bits.Len(uint(length/joinLength)) + 2
+}
+
+// merge assembles the leaves into a balanced tree, preserving their order
+func merge(pool *nodePool, leaves []*node) *node {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+
+	mid := len(leaves) >> 1
+	n := pool.get()
+	n.left = merge(pool, leaves[:mid])
+	n.right = merge(pool, leaves[mid:])
+	n.recount()
+	return n
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// get returns a zeroed node, reusing one from the pool if there are any
+func (p *nodePool) get() *node {
+	if p == nil || len(*p) == 0 {
+		return &node{}
+	}
+
+	last := len(*p) - 1
+	n := (*p)[last]
+	(*p)[last] = nil
+	*p = (*p)[:last]
+	*n = node{}
+	return n
+}
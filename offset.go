@@ -0,0 +1,54 @@
+package rope
+
+import (
+	"fmt"
+)
+
+// ByteOffsetToRuneOffset returns the rune offset of the rune which begins at
+// the given byte offset.  The end of the Rope is a valid offset.  An offset
+// outside the Rope returns ErrIndexOutOfRange, and one within the encoding of
+// a rune returns an error.
+func (r *Rope) ByteOffsetToRuneOffset(byteOffset int) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if byteOffset < 0 || byteOffset > r.root.byteLength {
+		return 0, ErrIndexOutOfRange
+	}
+
+	position := r.root.runeOffset(byteOffset)
+	if r.root.byteOffset(position) != byteOffset {
+		return 0, fmt.Errorf("byte offset is not on a rune boundary")
+	}
+	return position, nil
+}
+
+// InsertBytesAt adds the value to the Rope at the given byte offset, as
+// Insert does at a rune offset, for callers such as patching tools which
+// count in bytes.  The offset is checked by ByteOffsetToRuneOffset.
+func (r *Rope) InsertBytesAt(byteOffset int, value string) error {
+	position, err := r.ByteOffsetToRuneOffset(byteOffset)
+	if err != nil {
+		return err
+	}
+
+	return r.Insert(position, value)
+}
+
+// RemoveBytes removes the bytes between startByte and endByte, as Remove does
+// for a range of runes.  Both offsets are checked by ByteOffsetToRuneOffset,
+// so the range holds whole runes.
+func (r *Rope) RemoveBytes(startByte, endByte int) error {
+	start, err := r.ByteOffsetToRuneOffset(startByte)
+	if err != nil {
+		return err
+	}
+
+	end, err := r.ByteOffsetToRuneOffset(endByte)
+	if err != nil {
+		return err
+	}
+
+	return r.Remove(start, end)
+}
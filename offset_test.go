@@ -0,0 +1,61 @@
+package rope
+
+import (
+	"testing"
+)
+
+func Test_ByteOffsetToRuneOffset(t *testing.T) {
+	r := CreateRope("a🐿b")
+	for _, tc := range []struct{ byteOffset, expected int }{{0, 0}, {1, 1}, {5, 2}, {6, 3}} {
+		if position, err := r.ByteOffsetToRuneOffset(tc.byteOffset); err != nil || position != tc.expected {
+			t.Fatalf("Incorrect offset for byte %d: expected %d, got %d, %v", tc.byteOffset, tc.expected, position, err)
+		}
+	}
+	for _, byteOffset := range []int{2, 4} {
+		if _, err := r.ByteOffsetToRuneOffset(byteOffset); err == nil {
+			t.Fatalf("Expected error for byte %d within a rune", byteOffset)
+		}
+	}
+	for _, byteOffset := range []int{-1, 7} {
+		if _, err := r.ByteOffsetToRuneOffset(byteOffset); err != ErrIndexOutOfRange {
+			t.Fatalf("Byte %d: expected ErrIndexOutOfRange, got %v", byteOffset, err)
+		}
+	}
+}
+
+func Test_InsertBytesAt_RemoveBytes(t *testing.T) {
+	loopTest(t, "Bytes", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		init := string(runes)
+		r := CreateRope(init)
+
+		middle := len(string(runes[:stringSize.size/2]))
+		if err := r.InsertBytesAt(middle, "xyz"); err != nil {
+			t.Fatal(err)
+		}
+		expected := init[:middle] + "xyz" + init[middle:]
+		if r.String() != expected {
+			t.Fatal("Incorrect insert")
+		}
+
+		start := len(string(runes[:stringSize.size/4]))
+		if err := r.RemoveBytes(start, middle+3); err != nil {
+			t.Fatal(err)
+		}
+		expected = init[:start] + init[middle:]
+		if r.String() != expected {
+			t.Fatal("Incorrect remove")
+		}
+	})
+
+	r := CreateRope("a🐿b")
+	if err := r.InsertBytesAt(3, "x"); err == nil || r.String() != "a🐿b" {
+		t.Fatal("Insert within a rune was not rejected")
+	}
+	if err := r.RemoveBytes(1, 3); err == nil || r.String() != "a🐿b" {
+		t.Fatal("Remove ending within a rune was not rejected")
+	}
+	if err := r.RemoveBytes(1, 5); err != nil || r.String() != "ab" {
+		t.Fatalf("Incorrect remove of a whole rune: %q, %v", r.String(), err)
+	}
+}
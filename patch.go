@@ -0,0 +1,188 @@
+package rope
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// hunk is a parsed hunk of a unified diff, which replaces the lines of old,
+// starting at the 0-based line start, with the lines of new
+type hunk struct {
+	start int
+	old   string
+	new   string
+}
+
+// ApplyUnifiedDiff applies the hunks of a unified diff, such as produced by
+// diff -u or git diff, to the Rope.  Lines before the first hunk header, such
+// as the ---/+++ file names, are ignored.  Each hunk is located by the line
+// numbers in its header, and the context and removed lines it gives must
+// match the Rope exactly.  The hunks are then applied as by ApplyEdits.  If
+// any hunk does not match, or the patch is malformed, an error is returned
+// and the Rope is left unchanged.
+func (r *Rope) ApplyUnifiedDiff(patch string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	edits, err := r.unifiedDiffEdits(patch)
+	if err != nil {
+		return err
+	}
+	return r.applyEdits(edits)
+}
+
+// CheckUnifiedDiff returns the error which ApplyUnifiedDiff would return for
+// the patch, without applying it, as a dry run.  A nil result means that the
+// patch is well formed and every hunk matches the Rope.
+func (r *Rope) CheckUnifiedDiff(patch string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	_, err := r.unifiedDiffEdits(patch)
+	return err
+}
+
+// unifiedDiffEdits parses the patch and returns the edits which apply its
+// hunks, checking that each matches the Rope and that the result is not too
+// large
+func (r *Rope) unifiedDiffEdits(patch string) ([]EditOp, error) {
+	hunks, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := make([]EditOp, len(hunks))
+	growth := 0
+	for i, h := range hunks {
+		if h.start > r.root.newlines {
+			return nil, fmt.Errorf("hunk %d starts beyond the end of the rope", i+1)
+		}
+
+		start := r.root.lineStart(h.start)
+		end := start + len([]rune(h.old))
+		if end > r.root.length || r.root.substring(start, end) != h.old {
+			return nil, fmt.Errorf("hunk %d does not match at line %d", i+1, h.start+1)
+		}
+		if i > 0 && start < edits[i-1].End {
+			return nil, fmt.Errorf("hunk %d overlaps the previous hunk", i+1)
+		}
+
+		edits[i] = EditOp{start, end, h.new}
+		growth += len(h.new) - len(h.old)
+	}
+
+	if err := r.checkGrowth(growth); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// parseUnifiedDiff returns the hunks of the patch, in order
+func parseUnifiedDiff(patch string) ([]hunk, error) {
+	var hunks []hunk
+	lines := strings.SplitAfter(patch, "\n")
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(lines[i], "@@") {
+			i++
+			continue
+		}
+
+		fields := strings.Fields(lines[i])
+		if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+			return nil, fmt.Errorf("malformed hunk header %q", strings.TrimSpace(lines[i]))
+		}
+		oldStart, oldCount, err := parseHunkRange(fields[1][1:])
+		if err != nil {
+			return nil, err
+		}
+		_, newCount, err := parseHunkRange(fields[2][1:])
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		// A hunk which removes nothing gives the line after which it inserts,
+		// rather than the first line it replaces.
+		h := hunk{start: oldStart - 1}
+		if oldCount == 0 {
+			h.start = oldStart
+		}
+
+		var old, new []string
+		lastOld, lastNew := false, false
+		for len(old) < oldCount || len(new) < newCount || i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+			if i >= len(lines) || lines[i] == "" {
+				return nil, fmt.Errorf("hunk %d is truncated", len(hunks)+1)
+			}
+
+			line := lines[i]
+			i++
+			switch line[0] {
+			case ' ':
+				old = append(old, line[1:])
+				new = append(new, line[1:])
+				lastOld, lastNew = true, true
+			case '\n':
+				// Some tools write empty context lines without the space.
+				old = append(old, line)
+				new = append(new, line)
+				lastOld, lastNew = true, true
+			case '-':
+				old = append(old, line[1:])
+				lastOld, lastNew = true, false
+			case '+':
+				new = append(new, line[1:])
+				lastOld, lastNew = false, true
+			case '\\':
+				// "\ No newline at end of file" applies to the line before.
+				if lastOld {
+					old[len(old)-1] = strings.TrimSuffix(old[len(old)-1], "\n")
+				}
+				if lastNew {
+					new[len(new)-1] = strings.TrimSuffix(new[len(new)-1], "\n")
+				}
+			default:
+				return nil, fmt.Errorf("malformed line in hunk %d: %q", len(hunks)+1, strings.TrimSpace(line))
+			}
+		}
+		if len(old) != oldCount || len(new) != newCount {
+			return nil, fmt.Errorf("hunk %d does not match its header", len(hunks)+1)
+		}
+
+		h.old = strings.Join(old, "")
+		h.new = strings.Join(new, "")
+		hunks = append(hunks, h)
+	}
+	return hunks, nil
+}
+
+// parseHunkRange parses the start and count of one side of a hunk header,
+// where an omitted count is 1.  Lines are numbered from 1, so only a range
+// of no lines may start at 0.
+func parseHunkRange(s string) (start, count int, err error) {
+	startText, countText, found := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", s)
+	}
+	count = 1
+	if found {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, fmt.Errorf("malformed hunk range %q", s)
+		}
+	}
+	if start < 0 || count < 0 || start < 1 && count > 0 {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", s)
+	}
+	return start, count, nil
+}
@@ -0,0 +1,110 @@
+package rope
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ApplyUnifiedDiff(t *testing.T) {
+	old := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+	}{
+		{
+			"change",
+			"--- a/file\n+++ b/file\n@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			"one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		},
+		{
+			"several-hunks",
+			"@@ -1,2 +1,3 @@\n+zero\n one\n two\n@@ -8,3 +9,2 @@\n eight\n-nine\n ten\n",
+			"zero\none\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nten\n",
+		},
+		{
+			"insert-after",
+			"@@ -3,0 +4,2 @@\n+3.25\n+3.5\n",
+			"one\ntwo\nthree\n3.25\n3.5\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		},
+		{
+			"insert-at-start",
+			"@@ -0,0 +1 @@\n+zero\n",
+			"zero\none\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		},
+		{
+			"remove-final-newline",
+			"@@ -10 +10 @@\n-ten\n+ten\n\\ No newline at end of file\n",
+			"one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten",
+		},
+		{
+			"no-changes",
+			"--- a/file\n+++ b/file\n",
+			old,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope(old)
+			if err := r.CheckUnifiedDiff(tc.patch); err != nil || r.String() != old {
+				t.Fatalf("Dry run failed or changed the rope: %v", err)
+			}
+			if err := r.ApplyUnifiedDiff(tc.patch); err != nil {
+				t.Fatal(err)
+			}
+			if result := r.String(); result != tc.expected {
+				t.Fatalf("Incorrect result:\nExpected:\n%q\nGot:\n%q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func Test_ApplyUnifiedDiff_Errors(t *testing.T) {
+	old := "one\ntwo\nthree\n"
+	patches := map[string]string{
+		"context-mismatch": "@@ -1,2 +1,2 @@\n one\n-TWO\n+2\n",
+		"beyond-end":       "@@ -9,1 +9,1 @@\n-x\n+y\n",
+		"bad-header":       "@@ -1,x +1 @@\n one\n",
+		"zero-start":       "@@ -0,1 +1 @@\n-one\n+1\n",
+		"negative-start":   "@@ -1 +-1 @@\n-one\n+1\n",
+		"negative-count":   "@@ -1,-1 +1 @@\n+1\n",
+		"count-mismatch":   "@@ -1,2 +1,2 @@\n one\n",
+		"bad-line":         "@@ -1 +1 @@\n*one\n",
+		"second-fails":     "@@ -1 +1 @@\n-one\n+1\n@@ -3 +3 @@\n-four\n+4\n",
+		"overlap":          "@@ -1,2 +1,2 @@\n-one\n+1\n two\n@@ -2 +2 @@\n-two\n+2\n",
+	}
+
+	for name, patch := range patches {
+		r := CreateRope(old)
+		if err := r.CheckUnifiedDiff(patch); err == nil {
+			t.Fatalf("Expected dry run error for %s", name)
+		}
+		if err := r.ApplyUnifiedDiff(patch); err == nil {
+			t.Fatalf("Expected error for %s", name)
+		}
+		if r.String() != old {
+			t.Fatalf("Failed patch %s changed the rope", name)
+		}
+	}
+}
+
+func Test_ApplyUnifiedDiff_Large(t *testing.T) {
+	loopTest(t, "ApplyUnifiedDiff", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		lines := make([]string, 200)
+		for i := range lines {
+			lines[i] = charSet.generator(stringSize.size/50) + "\n"
+		}
+		r := CreateRope(strings.Join(lines, ""))
+
+		replacement := charSet.generator(10) + "\n"
+		patch := "@@ -100,3 +100,3 @@\n " + lines[99] + "-" + lines[100] + "+" + replacement + " " + lines[101]
+		if err := r.ApplyUnifiedDiff(patch); err != nil {
+			t.Fatal(err)
+		}
+		lines[100] = replacement
+		if r.String() != strings.Join(lines, "") {
+			t.Fatal("Incorrect result")
+		}
+	})
+}
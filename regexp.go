@@ -0,0 +1,118 @@
+package rope
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+)
+
+// FindSubmatchIndex returns the byte offsets of the leftmost match of re in
+// the Rope and of its subexpressions, as regexp's FindReaderSubmatchIndex
+// does: the pair for the match is followed by a pair for each group, with -1
+// for a group which took no part in it.  As in the regexp package, the
+// offsets count bytes rather than runes.  The Rope is read through a Reader,
+// so it is not copied into a single string.  If there is no match, the
+// result is nil.
+func (r *Rope) FindSubmatchIndex(re *regexp.Regexp) []int {
+	return re.FindReaderSubmatchIndex(&Reader{r: r})
+}
+
+// ReplaceFunc replaces each match of re in the Rope with the result of
+// calling repl with the matched text, as regexp.ReplaceAllStringFunc does for
+// a string, and returns the number of replacements.  The Rope is searched
+// through a Reader rather than as a single string, unless re holds an
+// assertion which looks at the text before where it is tried, such as ^, \A,
+// \b or \B; as the search through a Reader begins again after each match,
+// such a pattern is matched against the whole content as a string instead.
+// The replacements are made as by ApplyEdits: from last to first, so the
+// offsets of those still to be made are undisturbed, with Markers moved for
+// each of them, and with the tree balanced once at the end.
+func (r *Rope) ReplaceFunc(re *regexp.Regexp, repl func(match string) string) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return 0, ErrFrozen
+	}
+
+	var matches [][]int
+	if looksBehind(re) {
+		matches = re.FindAllStringIndex(r.String(), -1)
+	} else {
+		matches = r.findAllIndex(re)
+	}
+	if len(matches) == 0 {
+		return 0, nil
+	}
+
+	edits := make([]EditOp, len(matches))
+	for i, loc := range matches {
+		start := r.root.runeOffset(loc[0])
+		end := r.root.runeOffset(loc[1])
+		edits[i] = EditOp{start, end, repl(r.root.substring(start, end))}
+	}
+	if err := r.applyEdits(edits); err != nil {
+		return 0, err
+	}
+	return len(edits), nil
+}
+
+// findAllIndex returns the byte offsets of each match of re in the Rope, as
+// regexp.FindAllStringIndex does, searching through a Reader from the end of
+// each match for the next
+func (r *Rope) findAllIndex(re *regexp.Regexp) [][]int {
+	var matches [][]int
+	previous := -1
+	read := &Reader{r: r}
+	for read.pos <= r.root.byteLength {
+		base := read.pos
+		loc := re.FindReaderIndex(read)
+		if loc == nil {
+			break
+		}
+
+		// As with the regexp package, an empty match directly after the
+		// previous match is ignored, and is followed by a search from the
+		// next rune.
+		empty := loc[0] == loc[1]
+		read.pos = base + loc[1]
+		if !empty || base+loc[0] != previous {
+			matches = append(matches, []int{base + loc[0], base + loc[1]})
+			previous = read.pos
+		}
+		if empty {
+			if read.pos == r.root.byteLength {
+				break
+			}
+			read.ReadRune()
+		}
+	}
+	return matches
+}
+
+// looksBehind reports whether the pattern of re holds an assertion which
+// depends on the text before where it is tried: the start of the text or of
+// a line, or a word boundary.  A pattern which cannot be parsed again is
+// assumed to.
+func looksBehind(re *regexp.Regexp) bool {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return true
+	}
+
+	var walk func(*syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		switch re.Op {
+		case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+			return true
+		}
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(parsed)
+}
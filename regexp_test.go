@@ -0,0 +1,119 @@
+package rope
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_FindSubmatchIndex(t *testing.T) {
+	loopTest(t, "FindSubmatchIndex", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		r.Insert(stringSize.size/2, "key=🐈value;")
+		r.Append(charSet.generator(stringSize.size))
+		content := r.String()
+
+		for _, pattern := range []string{`(\w+)=(🐈)(\w*);`, `(key)=(x)?(🐈)`, `(nothing)(here)`} {
+			re := regexp.MustCompile(pattern)
+			expected := re.FindStringSubmatchIndex(content)
+			if loc := r.FindSubmatchIndex(re); !reflect.DeepEqual(loc, expected) {
+				t.Fatalf("Incorrect result for %s: expected %v, got %v", pattern, expected, loc)
+			}
+		}
+	})
+}
+
+func Test_ReplaceFunc(t *testing.T) {
+	tests := []struct {
+		pattern string
+		init    string
+	}{
+		{`o+`, "foo boo zoo"},
+		{`[aeiou]`, "🐿 rope data structure"},
+		{`x*`, "abc"},
+		{`a*`, "baaac"},
+		{`a*`, "aaa"},
+		{`\d+`, "no digits here"},
+		{`🐈+`, "a🐈🐈b🐈c"},
+		{`.`, ""},
+		{`^a`, "aaa"},
+		{`\Aa*`, "aab"},
+		{`(?m)^\w`, "one\ntwo\n\nthree"},
+		{`\bo`, "oo oops"},
+		{`\Bo`, "oo oops"},
+		{`\b`, "ab 🐿 cd"},
+		{`a$`, "aa\naa"},
+	}
+
+	for _, tc := range tests {
+		re := regexp.MustCompile(tc.pattern)
+		repl := func(match string) string {
+			return "<" + strings.ToUpper(match) + ">"
+		}
+
+		r := CreateRope(tc.init)
+		count, err := r.ReplaceFunc(re, repl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := re.ReplaceAllStringFunc(tc.init, repl); r.String() != expected {
+			t.Fatalf("Incorrect result for %q on %q: expected %q, got %q", tc.pattern, tc.init, expected, r.String())
+		}
+		if expected := len(re.FindAllStringIndex(tc.init, -1)); count != expected {
+			t.Fatalf("Incorrect count for %q on %q: expected %d, got %d", tc.pattern, tc.init, expected, count)
+		}
+	}
+}
+
+func Test_ReplaceFunc_Large(t *testing.T) {
+	loopTest(t, "ReplaceFunc", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		re := regexp.MustCompile(`[a-c]+|🐿`)
+		repl := func(match string) string {
+			return strings.Repeat("-", len(match)%3)
+		}
+
+		if _, err := r.ReplaceFunc(re, repl); err != nil {
+			t.Fatal(err)
+		}
+		if expected := re.ReplaceAllStringFunc(init, repl); r.String() != expected {
+			t.Fatalf("Incorrect result")
+		}
+		assertLogarithmicDepth(t, r)
+	})
+}
+
+func Test_ReplaceFunc_MaxBytes(t *testing.T) {
+	r := CreateRopeWithOptions("aaa", Options{MaxBytes: 5})
+	if _, err := r.ReplaceFunc(regexp.MustCompile(`a`), func(string) string { return "bb" }); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if r.String() != "aaa" {
+		t.Fatalf("Rejected replacement changed the rope")
+	}
+}
+
+func Test_ReplaceFunc_Markers(t *testing.T) {
+	r := CreateRope("one two three two one")
+	between, _ := r.Mark(9)
+	inside, _ := r.Mark(5)
+	var events []ChangeEvent
+	r.OnChange(func(e ChangeEvent) { events = append(events, e) })
+
+	// Each match is its own edit, so text between matches is untouched
+	if _, err := r.ReplaceFunc(regexp.MustCompile(`two`), strings.ToUpper); err != nil {
+		t.Fatal(err)
+	}
+	if position, valid := between.Position(); position != 9 || !valid {
+		t.Fatalf("Marker between matches was moved: got %d, %t", position, valid)
+	}
+	if _, valid := inside.Position(); valid {
+		t.Fatal("Marker inside a match was not invalidated")
+	}
+	if len(events) != 2 || events[0].Start != 14 || events[1].Start != 4 || events[1].End != 7 {
+		t.Fatalf("Incorrect events: %v", events)
+	}
+}
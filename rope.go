@@ -1,380 +1,1636 @@
 package rope
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode/utf8"
+	"unsafe"
 )
 
+// ErrTooLarge is returned by an edit which would make the Rope larger than
+// its Options.MaxBytes
+var ErrTooLarge = errors.New("rope would exceed maximum size")
+
+// ErrSkipped is returned by InsertIf when its condition does not hold and
+// nothing is inserted
+var ErrSkipped = errors.New("insert skipped")
+
+// ErrRevisionChanged is returned by CompareAndSwapRevision when the Rope has
+// been edited since the expected revision
+var ErrRevisionChanged = errors.New("revision has changed")
+
+// ErrFrozen is returned by an edit of a Rope which has been frozen
+var ErrFrozen = errors.New("rope is frozen")
+
+// ErrIndexOutOfRange is returned when an offset is outside the Rope, either
+// as it is or wrapped with the offset, so that it is tested with errors.Is
+var ErrIndexOutOfRange = errors.New("index is not within rope bounds")
+
+// ErrInvalidRange is returned, either as it is or wrapped, when the start of a
+// range is after its end
+var ErrInvalidRange = errors.New("start is after end")
+
+// bom is the UTF-8 encoding of the byte order mark, U+FEFF
+const bom = "\xEF\xBB\xBF"
+
 // This code is a mostly-direct translation of
 // https://github.com/component/rope.  Many thanks to the contributers and
 // maintainers of http://component.github.io/ for their unknown contributions
 // to this project.
 
-const (
-	splitLength = 512
-	joinLength  = 256
+// Rope is a data structure that represents a string.  Internally, the string
+// is fractured into smaller strings to facilitate faster editing at the
+// expense of memory usage
+type Rope struct {
+	root     *node
+	options  Options
+	revision atomic.Uint64
+	swap     sync.Mutex
+	markers  []*Marker
+
+	// contentID caches the result of ContentID as of contentIDRevision
+	contentID         string
+	contentIDRevision uint64
+
+	// spine is the path from the root to the rightmost leaf, kept between
+	// calls to Append so that it need not descend the tree each time.  It is
+	// cleared by any other edit.
+	spine []*node
+
+	// frozen is set by Freeze, after which the Rope may not be edited
+	frozen bool
+
+	// pool holds the nodes of the tree emptied by Reset, for SetContent to
+	// build the next one from
+	pool nodePool
+
+	// transaction is the state saved by Begin, until Commit or Rollback
+	transaction *transaction
+
+	// listeners are the functions registered with OnChange, and
+	// observedBytes is the byte length of the content they last saw
+	listeners     []*listener
+	observedBytes int
+}
+
+// Options configures the behavior of a Rope
+type Options struct {
+	// DisableAutoBalance stops the Rope from rebalancing its tree after each
+	// edit.  By default, an edit which leaves part of the tree much deeper
+	// than its length warrants is followed by a rebuild of that part.  This
+	// keeps every operation logarithmic, at the cost of the occasional
+	// rebuild.  With this option, that work is left to explicit calls to
+	// Rebalance; until then, each leaf split along the same path, as happens
+	// when repeatedly appending, deepens the tree further.
+	DisableAutoBalance bool
+
+	// MaxBytes limits the byte length of the Rope.  An edit which would grow
+	// the Rope beyond it fails with ErrTooLarge before any change is made.
+	// Edits which do not grow the Rope are always allowed, so a Rope created
+	// with more content can still be reduced.  SetContent, which cannot fail,
+	// is not limited.  Zero means no limit.
+	MaxBytes int
+}
+
+// Range is a span of a Rope, from Start up to but not including End.  Whether
+// the offsets count runes or bytes is given by the method which uses it.
+type Range struct {
+	Start int
+	End   int
+}
+
+// CompareAndSwapRevision calls mutate to edit the Rope only if its current
+// revision is the expected one, and returns the revision which results.  If
+// the Rope has been edited since the expected revision, mutate is not called,
+// and the current revision is returned with ErrRevisionChanged.  An error
+// returned by mutate is passed back to the caller.
+//
+// Calls to CompareAndSwapRevision are serialized with one another, so writers
+// which all edit through it can detect conflicting edits without a lock of
+// their own.  They are not serialized with edits made directly, and mutate
+// must not call CompareAndSwapRevision itself.
+func (r *Rope) CompareAndSwapRevision(expected uint64, mutate func(*Rope) error) (uint64, error) {
+	r.swap.Lock()
+	defer r.swap.Unlock()
+
+	if revision := r.revision.Load(); revision != expected {
+		return revision, ErrRevisionChanged
+	}
+
+	err := mutate(r)
+	return r.revision.Load(), err
+}
+
+// Concat returns a new Rope holding the content of left followed by that of
+// right, with the options of left.  The trees of both are joined under a new
+// root rather than copied, so this is constant time apart from any
+// rebalancing, and subsequent edits to any of the three Ropes do not affect
+// the others.  If either side is empty or nil, the result shares the tree of
+// the other side alone.
+func Concat(left, right *Rope) *Rope {
+	var options Options
+	if left != nil {
+		options = left.options
+	} else if right != nil {
+		options = right.options
+	}
+
+	// The trees are now reachable from the new Rope as well, so are marked
+	// as shared, as by Root
+	var nodes []*node
+	for _, side := range []*Rope{left, right} {
+		if !side.IsEmpty() {
+			side.root.shared.Store(true)
+			nodes = append(nodes, side.root)
+		}
+	}
+
+	result := &Rope{options: options}
+	switch len(nodes) {
+	case 0:
+		result.root = newNode("")
+	case 1:
+		result.root = nodes[0]
+	default:
+		result.root = concat(nodes[0], nodes[1])
+		result.balance()
+	}
+	return result
+}
+
+// Repeat returns a new Rope holding count copies of s, as strings.Repeat
+// does.  A leaf of copies is built once and then doubled by joining a tree
+// to itself, so the copies share their structure and the cost in time and
+// memory is logarithmic in count; as elsewhere, a later edit copies only the
+// nodes which it touches.  As with strings.Repeat, it panics if count is
+// negative or the result would be too long.
+func Repeat(s string, count int) *Rope {
+	if count < 0 {
+		panic("rope: negative Repeat count")
+	}
+	if count > 0 && len(s) > math.MaxInt/count {
+		panic("rope: Repeat output length overflow")
+	}
+	if s == "" || count == 0 {
+		return CreateRope("")
+	}
+
+	// Each power of two copies of the chunk which count needs is joined in
+	perChunk := min(max(1, fillLength/utf8.RuneCountInString(s)), count)
+	chunk := newNode(strings.Repeat(s, perChunk))
+	chunk.share()
+	var root *node
+	for chunks := count / perChunk; chunks > 0; chunks >>= 1 {
+		if chunks&1 == 1 {
+			if root == nil {
+				root = chunk
+			} else {
+				root = concat(root, chunk)
+			}
+		}
+		if chunks > 1 {
+			chunk = concat(chunk, chunk)
+			chunk.shared.Store(true)
+		}
+	}
+	if rest := count % perChunk; rest > 0 {
+		root = concat(root, newNode(strings.Repeat(s, rest)))
+	}
+
+	r := &Rope{root: root}
+	r.balance()
+	return r
+}
+
+// CopyRange copies the bytes of the runes between start and end into dst,
+// and returns the number of bytes copied, like copy(dst, s[start:end]) for a
+// string.  Nothing is allocated.  It is an error for dst to be too small to
+// hold the range, in which case nothing is copied.
+func (r *Rope) CopyRange(dst []byte, start, end int) (int, error) {
+	if r == nil {
+		return 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if start < 0 || end > r.root.length {
+		return 0, fmt.Errorf("range [%d, %d): %w", start, end, ErrIndexOutOfRange)
+	}
+	if start > end {
+		return 0, fmt.Errorf("range [%d, %d): %w", start, end, ErrInvalidRange)
+	}
+
+	if size := r.root.byteOffset(end) - r.root.byteOffset(start); len(dst) < size {
+		return 0, fmt.Errorf("dst is too small: %d bytes are needed", size)
+	}
+
+	copied := 0
+	r.root.walkRange(start, end, func(value string) bool {
+		copied += copy(dst[copied:], value)
+		return true
+	})
+	return copied, nil
+}
+
+// CreateRope creates a Rope with the given initial value
+func CreateRope(initial string) *Rope {
+	return &Rope{root: newNode(initial)}
+}
+
+// CreateRopeFromBytes creates a Rope holding the content of data.  The leaves
+// of the Rope refer directly into data rather than to a copy of it, so the
+// Rope takes ownership of data: the caller must not modify it afterwards, for
+// as long as this Rope or any Rope sharing its structure lives.  The Rope
+// itself never writes to data, as edits copy the leaves which they touch.
+func CreateRopeFromBytes(data []byte) *Rope {
+	if len(data) == 0 {
+		return CreateRope("")
+	}
+
+	return &Rope{root: build(nil, unsafe.String(&data[0], len(data)), fillLength)}
+}
+
+// CreateRopeFromReader creates a Rope holding the content read from r until
+// io.EOF.  The content is passed through a Builder as it is read, so it is
+// never held as a single string, and a rune divided between reads is kept
+// whole.  Any other error from r is returned with no Rope.
+func CreateRopeFromReader(r io.Reader) (*Rope, error) {
+	var b Builder
+	if _, err := io.Copy(&b, r); err != nil {
+		return nil, err
+	}
+	return b.Rope(), nil
+}
+
+// CreateRopeFromSeq creates a Rope holding the concatenation of the strings
+// yielded by seq, in one pass.  Small strings are gathered together and large
+// ones cut up, so the tree is balanced and its leaves full however the
+// content is divided.
+func CreateRopeFromSeq(seq iter.Seq[string]) *Rope {
+	var b Builder
+	for s := range seq {
+		b.WriteString(s)
+	}
+	return b.Rope()
+}
+
+// CreateRopeWithOptions creates a Rope with the given initial value and
+// options
+func CreateRopeWithOptions(initial string, options Options) *Rope {
+	return &Rope{root: newNode(initial), options: options}
+}
+
+// Alter replaces the runes between start and end with the value, as a single
+// edit: both bounds are checked before the tree is touched, so an invalid
+// range leaves the Rope unchanged, and the replacement is made in one pass
+// down the tree rather than as a Remove followed by an Insert.  An offset
+// outside the Rope returns ErrIndexOutOfRange, and a start after the end
+// returns ErrInvalidRange, each wrapped with the range.
+func (r *Rope) Alter(start, end int, value string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if start < 0 || start > r.root.length || end < 0 || end > r.root.length {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrIndexOutOfRange)
+	}
+
+	if start > end {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrInvalidRange)
+	}
+
+	if err := r.checkSize(start, end, value); err != nil {
+		return err
+	}
+
+	r.alter(start, end, value)
+	return nil
+}
+
+// Append adds the value to the end of the Rope.  While the last leaf has room
+// for the value, it is grown in place through a cached path to it, without
+// searching the tree; otherwise the value is inserted as by Insert.
+func (r *Rope) Append(value string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if err := r.checkSize(r.root.length, r.root.length, value); err != nil {
+		return err
+	}
+
+	if r.spine == nil {
+		for n := r.root; ; n = n.right {
+			r.spine = append(r.spine, n)
+			if n.value != nil {
+				break
+			}
+		}
+	}
+
+	valueLength := utf8.RuneCountInString(value)
+	leaf := r.spine[len(r.spine)-1]
+	if leaf.length+valueLength > splitLength {
+		return r.Insert(r.root.length, value)
+	}
+	for _, n := range r.spine {
+		if n.shared.Load() {
+			return r.Insert(r.root.length, value)
+		}
+	}
+
+	length := r.root.length
+	s := *leaf.value + value
+	leaf.value = &s
+	newlines := strings.Count(value, "\n")
+	for _, n := range r.spine {
+		n.length += valueLength
+		n.byteLength += len(value)
+		n.newlines += newlines
+		n.edits++
+	}
+	r.changed(length, length, length)
+	return nil
+}
+
+// AppendRuneN appends count copies of the rune to the end of the Rope.  The
+// last leaf is grown in place as far as it can be, as by Append, and the rest
+// of the runs are added as new leaves of a balanced subtree.  Those leaves all
+// hold slices of a single string, so a long run costs little more memory than
+// one leaf.
+func (r *Rope) AppendRuneN(ru rune, count int) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if count < 0 {
+		return fmt.Errorf("count is negative")
+	}
+
+	if !utf8.ValidRune(ru) {
+		return fmt.Errorf("rune is not valid")
+	}
+
+	s := string(ru)
+	if err := r.checkGrowth(count * len(s)); err != nil {
+		return err
+	}
+
+	last, _ := r.root.locate(max(r.root.length-1, 0))
+	fill := min(count, max(splitLength-last.length, 0))
+	if fill > 0 {
+		if err := r.Append(strings.Repeat(s, fill)); err != nil {
+			return err
+		}
+		count -= fill
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// The runes are spread evenly over the leaves, as by appendLeaves
+	piece := strings.Repeat(s, min(count, fillLength))
+	leaves := make([]*node, (count+fillLength-1)/fillLength)
+	for i := range leaves {
+		runes := count*(i+1)/len(leaves) - count*i/len(leaves)
+		value := piece[:runes*len(s)]
+		newlines := 0
+		if ru == '\n' {
+			newlines = runes
+		}
+		leaves[i] = &node{value: &value, length: runes, byteLength: len(value), newlines: newlines}
+	}
+	spreadEdits(leaves, 1)
+
+	length := r.root.length
+	r.root = concat(r.root, merge(nil, leaves))
+	r.edited(length, length, length)
+	return nil
+}
+
+// BalanceWithFill rebuilds the tree with every leaf filled to about the given
+// fraction of the largest leaf size, so that there is room for the leaves to
+// grow in place before they must be split.  A ratio of 0.8 leaves a fifth of
+// each leaf free.  Ratios are clamped to between a quarter and one, as the
+// tree joins leaves smaller than that.  The content is unchanged.
+func (r *Rope) BalanceWithFill(ratio float64) {
+	if r.frozen {
+		return
+	}
+
+	if ratio < 0.25 {
+		ratio = 0.25
+	} else if ratio > 1 {
+		ratio = 1
+	}
+	leafLength := int(ratio * splitLength)
+
+	var buf strings.Builder
+	buf.Grow(r.root.byteLength)
+	r.root.writeTo(&buf)
+
+	r.spine = nil
+	pool := r.root.recycle(nil)
+	r.root = build(&pool, buf.String(), leafLength)
+}
+
+// ByteLength returns the number of bytes necessary to store a contiguous
+// representation of the Rope's contents
+func (r *Rope) ByteLength() int {
+	return r.root.byteLength
+}
+
+// Cut removes the runes between start and end, as Remove does, and returns
+// them, for a kill ring or an undo history.  As with ReplaceRangeReturning,
+// the removed runes are collected as the edit descends the tree rather than
+// read beforehand.  Invalid offsets return the errors of Remove, and leave
+// the Rope unchanged.
+func (r *Rope) Cut(start, end int) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return "", ErrFrozen
+	}
+
+	if start < 0 || start > r.root.length || end < 0 || end > r.root.length {
+		return "", ErrIndexOutOfRange
+	}
+	if start > end {
+		return "", ErrInvalidRange
+	}
+
+	if start == end {
+		return "", nil
+	}
+
+	var buf strings.Builder
+	length := r.root.length
+	r.root = r.root.mutable()
+	r.root.alter(start, end, "", &buf)
+	r.edited(start, end, length)
+	return buf.String(), nil
+}
+
+// Duplicate inserts a copy of the runes between start and end at dest.  The
+// copy shares its structure with the original, so the duplicated runes are
+// not copied, and later edits to either do not affect the other.
+func (r *Rope) Duplicate(start, end, dest int) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if start < 0 || end > r.root.length {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrIndexOutOfRange)
+	}
+	if start > end {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrInvalidRange)
+	}
+
+	if dest < 0 || dest > r.root.length {
+		return fmt.Errorf("dest %d: %w", dest, ErrIndexOutOfRange)
+	}
+
+	if err := r.checkGrowth(r.root.byteOffset(end) - r.root.byteOffset(start)); err != nil {
+		return err
+	}
+
+	if start == end {
+		return nil
+	}
+
+	r.insertNode(dest, r.root.slice(start, end))
+	return nil
+}
+
+// Freeze makes the Rope read-only.  Every later edit fails with ErrFrozen,
+// except SetContent and Reset, which have no error to return and so panic
+// with it; Rebalance and BalanceWithFill do nothing.  Once frozen, a Rope may
+// be read from several goroutines at once without a lock, as no read of it
+// writes to the Rope or its tree; Mark is not a read, as it records the
+// Marker in the Rope.  Freeze itself must not be called concurrently with any
+// other method, and a Rope cannot be thawed; take a Snapshot of it for an
+// editable copy.
+func (r *Rope) Freeze() {
+	r.frozen = true
+	r.spine = nil
+	r.root.share()
+}
+
+// Gather returns a new Rope holding the runes of the ranges, in the order in
+// which they appear in this Rope.  Ranges which overlap or touch are merged
+// first, so no rune appears twice.  As with Prefix, the subtrees of the
+// ranges are shared with this Rope rather than copied.
+func (r *Rope) Gather(ranges []Range) (*Rope, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	for _, rg := range ranges {
+		if rg.Start < 0 || rg.End > r.root.length {
+			return nil, fmt.Errorf("range [%d, %d): %w", rg.Start, rg.End, ErrIndexOutOfRange)
+		}
+		if rg.Start > rg.End {
+			return nil, fmt.Errorf("range [%d, %d): %w", rg.Start, rg.End, ErrInvalidRange)
+		}
+	}
+
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return a.Start - b.Start
+	})
+
+	var pieces []*node
+	for i := 0; i < len(sorted); {
+		merged := sorted[i]
+		for i++; i < len(sorted) && sorted[i].Start <= merged.End; i++ {
+			merged.End = max(merged.End, sorted[i].End)
+		}
+		if merged.Start < merged.End {
+			pieces = append(pieces, r.root.slice(merged.Start, merged.End))
+		}
+	}
+	if len(pieces) == 0 {
+		return r.sub(newNode("")), nil
+	}
+
+	rope := r.sub(merge(nil, pieces))
+	rope.balance()
+	return rope, nil
+}
+
+// InLeaf returns the bytes of the runes between start and end if they lie
+// within a single leaf, without copying them, and reports whether they do.
+// The bytes are those of the leaf's string, so they must not be modified.
+// When the range is split between leaves, or is not within the Rope, false is
+// returned, and the content must be read by other means.
+func (r *Rope) InLeaf(start, end int) ([]byte, bool) {
+	if start < 0 || end > r.root.length || start > end {
+		return nil, false
+	}
+
+	n := r.root
+	for n.value == nil {
+		leftLength := n.left.length
+		if end <= leftLength {
+			n = n.left
+		} else if start >= leftLength {
+			start -= leftLength
+			end -= leftLength
+			n = n.right
+		} else {
+			return nil, false
+		}
+	}
+
+	s := (*n.value)[n.findByteOffsets(start):n.findByteOffsets(end)]
+	return unsafe.Slice(unsafe.StringData(s), len(s)), true
+}
+
+// Insert adds the provided value to the rope at the given rune-offset
+// position.  A position outside the Rope returns ErrIndexOutOfRange, and the
+// Rope is left unchanged.
+func (r *Rope) Insert(position int, value string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if position < 0 || position > r.root.length {
+		return ErrIndexOutOfRange
+	}
+
+	if err := r.checkSize(position, position, value); err != nil {
+		return err
+	}
+
+	length := r.root.length
+	r.root = r.root.mutable()
+	r.root.insert(position, value)
+	r.edited(position, position, length)
+	return nil
+}
+
+// InsertAtAll inserts the value at each of the rune offsets, as for several
+// cursors at once.  The offsets refer to the Rope before any insert, and may
+// be given in any order; the inserts are made from the highest offset to the
+// lowest, so that each is made where it was meant, and the tree is balanced
+// once at the end.  If any offset is invalid, the Rope is left unchanged.
+func (r *Rope) InsertAtAll(positions []int, value string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	for _, position := range positions {
+		if position < 0 || position > r.root.length {
+			return fmt.Errorf("position %d: %w", position, ErrIndexOutOfRange)
+		}
+	}
+
+	if err := r.checkGrowth(len(value) * len(positions)); err != nil {
+		return err
+	}
+
+	if value == "" || len(positions) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(positions)
+	slices.Sort(sorted)
+	valueLength := utf8.RuneCountInString(value)
+	r.root = r.root.mutable()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		r.root.insert(sorted[i], value)
+		r.moveMarkers(sorted[i], sorted[i], valueLength)
+		r.notify(sorted[i], sorted[i], valueLength)
+	}
+	r.spine = nil
+	r.balance()
+	r.revision.Add(1)
+	return nil
+}
+
+// InsertIf inserts the value at the given rune-offset position only if cond
+// returns true for the runes immediately before and after the position, and
+// otherwise returns ErrSkipped.  A rune beyond either end of the Rope is
+// passed as -1, and ok reports whether both runes exist.
+func (r *Rope) InsertIf(position int, value string, cond func(before, after rune, ok bool) bool) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if position < 0 || position > r.root.length {
+		return fmt.Errorf("position %d: %w", position, ErrIndexOutOfRange)
+	}
+
+	before, after := rune(-1), rune(-1)
+	if position > 0 {
+		before = r.root.runeAt(position - 1)
+	}
+	if position < r.root.length {
+		after = r.root.runeAt(position)
+	}
+
+	if !cond(before, after, before != -1 && after != -1) {
+		return ErrSkipped
+	}
+	return r.Insert(position, value)
+}
+
+// InsertRope inserts the content of other at the given rune offset.  As with
+// Concat, the tree of other is joined into this one rather than copied, and
+// subsequent edits to either Rope do not affect the other.  A position
+// outside the Rope returns ErrIndexOutOfRange, and a nil or empty other
+// leaves the Rope unchanged.
+func (r *Rope) InsertRope(position int, other *Rope) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if position < 0 || position > r.root.length {
+		return ErrIndexOutOfRange
+	}
+
+	if other.IsEmpty() {
+		return nil
+	}
+
+	if err := r.checkGrowth(other.root.byteLength); err != nil {
+		return err
+	}
+
+	// The tree of other is now reachable from this Rope as well, so is
+	// marked as shared, as by Root
+	other.root.shared.Store(true)
+	r.insertNode(position, other.root)
+	return nil
+}
+
+// HasBOM reports whether the Rope begins with a UTF-8 byte order mark.  The
+// bytes are read across leaf boundaries, so a mark which has been divided
+// between leaves is still found.
+func (r *Rope) HasBOM() bool {
+	if r.root.byteLength < len(bom) {
+		return false
+	}
+
+	var prefix [len(bom)]byte
+	copied := 0
+	r.root.walk(func(value string) bool {
+		copied += copy(prefix[copied:], value)
+		return copied < len(prefix)
+	})
+	return string(prefix[:]) == bom
+}
+
+// IsFrozen reports whether Freeze has been called on the Rope
+func (r *Rope) IsFrozen() bool {
+	return r.frozen
+}
+
+// IsEmpty reports whether the Rope holds no runes.  An empty Rope is
+// represented by a root leaf holding the empty string, so this is a check of
+// the root's cached length and never traverses the tree.
+func (r *Rope) IsEmpty() bool {
+	return r == nil || r.root == nil || r.root.length == 0
+}
+
+// LeafBoundaries returns the byte offset at which each leaf of the tree
+// begins, in order, starting with 0.  Data derived from the content of each
+// leaf can be cached against these offsets, as an edit rebuilds only the
+// leaves it touches.  The boundaries are those of the tree as it is now, and
+// any edit may change them, so they must be queried again after each one.
+func (r *Rope) LeafBoundaries() []int {
+	var boundaries []int
+	offset := 0
+	r.root.walk(func(value string) bool {
+		boundaries = append(boundaries, offset)
+		offset += len(value)
+		return true
+	})
+	return boundaries
+}
+
+// Length returns the number of runes in the Rope
+func (r *Rope) Length() int {
+	return r.root.length
+}
+
+// NewReader returns an `io.Reader` that will allow consuming the rope as a
+// contiguous stream of bytes.
+func (r *Rope) NewReader() io.Reader {
+	return &Reader{r: r}
+}
+
+// Lengths returns both the number of runes and the number of bytes in the
+// Rope, from the counts cached at the root of a single tree
+func (r *Rope) Lengths() (runes int, byteLength int) {
+	root := r.root
+	return root.length, root.byteLength
+}
+
+// Move moves the runes between start and end so that they begin at dest,
+// where dest is an offset before the move.  The Rope is cut and rejoined
+// around the block, so the moved runes are not copied.  Markers within the
+// span of the move are invalidated, as for any replacement of it.  It is an
+// error for dest to lie inside the block; moving it to either of its own
+// ends does nothing.
+func (r *Rope) Move(start, end, dest int) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if start < 0 || end > r.root.length {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrIndexOutOfRange)
+	}
+	if start > end {
+		return fmt.Errorf("range [%d, %d): %w", start, end, ErrInvalidRange)
+	}
+
+	if dest < 0 || dest > r.root.length {
+		return fmt.Errorf("dest %d: %w", dest, ErrIndexOutOfRange)
+	}
 
-	rebalanceRatio = 1.2
-)
+	if dest > start && dest < end {
+		return fmt.Errorf("dest is within the moved range")
+	}
 
-// Rope is a data structure that represents a string.  Internally, the string
-// is fractured into smaller strings to facilitate faster editing at the
-// expense of memory usage
-type Rope struct {
-	right      *Rope
-	left       *Rope
-	value      *string
-	length     int
-	byteLength int
+	if dest == start || dest == end || start == end {
+		return nil
+	}
+
+	first, second := min(start, dest), max(end, dest)
+	var pieces [2]*node
+	if dest < start {
+		pieces = [2]*node{r.root.slice(start, end), r.root.slice(dest, start)}
+	} else {
+		pieces = [2]*node{r.root.slice(end, dest), r.root.slice(start, end)}
+	}
+
+	length := r.root.length
+	r.root = concat(
+		concat(r.root.slice(0, first), pieces[0]),
+		concat(pieces[1], r.root.slice(second, length)),
+	)
+	r.edited(first, second, length)
+	return nil
 }
 
-// CreateRope creates a Rope with the given initial value
-func CreateRope(initial string) *Rope {
-	r := &Rope{nil, nil, &initial, utf8.RuneCountInString(initial), len(initial)}
-	r.adjust()
-	return r
+// NewReadCloser returns an io.ReadCloser over the bytes of the Rope, so that
+// callers can release a reader with Close however the Rope is stored.  A
+// Reader holds no resources of its own, so Close does nothing.
+func (r *Rope) NewReadCloser() io.ReadCloser {
+	return &Reader{r: r}
 }
 
-func (r *Rope) Alter(start, end int, value string) error {
+// OpenReader returns an io.ReadSeeker over the bytes of the Rope, for use
+// with APIs such as http.ServeContent which read files by range
+func (r *Rope) OpenReader() io.ReadSeeker {
+	return &Reader{r: r}
+}
+
+// Overwrite replaces the runes from start onwards with s, rune for rune, as
+// typing does in overwrite mode: as many runes are replaced as s holds, and
+// the Rope is only lengthened by the part of s which runs past its end.  The
+// replacement is made in one pass down the tree, as by Alter.  A start
+// outside the Rope returns ErrIndexOutOfRange.
+func (r *Rope) Overwrite(start int, s string) error {
 	if r == nil {
 		return fmt.Errorf("Nil pointer receiver")
 	}
 
-	if start < 0 || start > r.length {
-		return fmt.Errorf("start is not within rope bounds")
+	if r.frozen {
+		return ErrFrozen
 	}
 
-	if end < 0 || end > r.length {
-		return fmt.Errorf("end is not within rope bounds")
+	if start < 0 || start > r.root.length {
+		return ErrIndexOutOfRange
 	}
 
-	if start > end {
-		return fmt.Errorf("start is after end")
+	end := min(start+utf8.RuneCountInString(s), r.root.length)
+	if err := r.checkSize(start, end, s); err != nil {
+		return err
 	}
 
-	if start == end {
-		// This is a pure insert
-		if value == "" {
-			// No-op; nothing to insert
-			return nil
-		}
+	r.alter(start, end, s)
+	return nil
+}
 
-		return r.insert(start, value)
+// Partition divides the Rope into up to n contiguous sub-ropes of roughly
+// equal byte length, for processing concurrently.  Boundaries fall on rune
+// boundaries, so no multi-byte character is divided between two partitions,
+// and depend only on the content of the Rope, so they are reproducible.
+// Partitions which would be empty are omitted.  The partitions share their
+// structure with this Rope, and concatenated in order they equal it.
+func (r *Rope) Partition(n int) []*Rope {
+	if n < 1 {
+		return nil
+	}
 
-	} else if value == "" {
-		// This is a pure remove
-		return r.remove(start, end)
+	parts := make([]*Rope, 0, n)
+	start := 0
+	for i := 1; i <= n; i++ {
+		end := r.root.length
+		if i < n {
+			end = r.root.runeOffset(r.root.byteLength * i / n)
+		}
+		if end > start {
+			parts = append(parts, r.sub(r.root.slice(start, end)))
+			start = end
+		}
 	}
+	return parts
+}
 
-	return r.alter(start, end, value)
+// Pipe returns a Reader of the content of the Rope passed through each of
+// the transforms in turn, each wrapping the Reader returned by the one
+// before.  Nothing is read until the returned Reader is, so the content is
+// streamed through the chain rather than transformed in full at each step.
+// With no transforms, it is the Rope's own Reader.
+func (r *Rope) Pipe(transforms ...func(io.Reader) io.Reader) io.Reader {
+	var read io.Reader = &Reader{r: r}
+	for _, transform := range transforms {
+		read = transform(read)
+	}
+	return read
 }
 
-// ByteLength returns the number of bytes necessary to store a contiguous
-// representation of the Rope's contents
-func (r *Rope) ByteLength() int {
-	return r.byteLength
+// Prefix returns a new Rope holding the first n runes of this Rope.  The
+// new Rope shares its structure with this one, so the cost is proportional
+// to the depth of the tree rather than to n.  Subsequent edits to either Rope
+// do not affect the other.
+func (r *Rope) Prefix(n int) (*Rope, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if n < 0 || n > r.root.length {
+		return nil, fmt.Errorf("n %d: %w", n, ErrIndexOutOfRange)
+	}
+
+	return r.sub(r.root.slice(0, n)), nil
 }
 
-// Insert adds the provided value to the rope at the given rune-offset
-// position
-func (r *Rope) Insert(position int, value string) error {
+// Prepend adds the value to the start of the Rope.  It is the counterpart of
+// Append: while the first leaf has room for the value, it is grown in place
+// along the left edge of the tree, without searching by offset; otherwise the
+// value is inserted as by Insert.
+func (r *Rope) Prepend(value string) error {
 	if r == nil {
 		return fmt.Errorf("Nil pointer receiver")
 	}
 
-	if position < 0 || position > r.length {
-		return fmt.Errorf("position is not within rope bounds")
+	if r.frozen {
+		return ErrFrozen
 	}
 
-	return r.insert(position, value)
-}
+	if err := r.checkSize(0, 0, value); err != nil {
+		return err
+	}
 
-// Length returns the number of runes in the Rope
-func (r *Rope) Length() int {
-	return r.length
-}
+	var path []*node
+	for n := r.root; ; n = n.left {
+		if n.shared.Load() {
+			return r.Insert(0, value)
+		}
+		path = append(path, n)
+		if n.value != nil {
+			break
+		}
+	}
 
-// NewReader returns an `io.Reader` that will allow consuming the rope as a
-// contiguous stream of bytes.
-func (r *Rope) NewReader() io.Reader {
-	return &Reader{0, r}
+	valueLength := utf8.RuneCountInString(value)
+	leaf := path[len(path)-1]
+	if leaf.length+valueLength > splitLength {
+		return r.Insert(0, value)
+	}
+
+	// The nodes are grown in place, so a cached spine to the last leaf,
+	// which may include some of them, remains valid
+	length := r.root.length
+	s := value + *leaf.value
+	leaf.value = &s
+	newlines := strings.Count(value, "\n")
+	for _, n := range path {
+		n.length += valueLength
+		n.byteLength += len(value)
+		n.newlines += newlines
+		n.edits++
+	}
+	r.changed(0, 0, length)
+	return nil
 }
 
 // Rebalance rebalances the b-tree structure
 func (r *Rope) Rebalance() {
-	if r.value == nil {
-		leftLength := r.left.length
-		rightLength := r.right.length
-
-		if float32(leftLength)/float32(rightLength) > rebalanceRatio ||
-			float32(rightLength)/float32(leftLength) > rebalanceRatio {
-			r.rebuild()
-		} else {
-			r.left.Rebalance()
-			r.right.Rebalance()
-		}
+	if r.frozen {
+		return
 	}
+
+	r.spine = nil
+	r.root = r.root.mutable()
+	r.root.rebalance()
 }
 
 // Remove deletes the runes between the start and end point.  The start
-// and end are the rune offsets from the start of the rope.
+// and end are the rune offsets from the start of the rope.  An offset outside
+// the Rope returns ErrIndexOutOfRange, and a start after the end returns
+// ErrInvalidRange; either way the Rope is left unchanged.
 func (r *Rope) Remove(start, end int) error {
 	if r == nil {
 		return fmt.Errorf("Nil pointer receiver")
 	}
 
-	if start < 0 || start > r.length {
-		return fmt.Errorf("Start is not within rope bounds")
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if start < 0 || start > r.root.length || end < 0 || end > r.root.length {
+		return ErrIndexOutOfRange
+	}
+	if start > end {
+		return ErrInvalidRange
+	}
+
+	length := r.root.length
+	r.root = r.root.mutable()
+	r.root.remove(start, end)
+	r.edited(start, end, length)
+	return nil
+}
+
+// SetContent replaces the entire content of the Rope with s, building a
+// balanced tree for it.  The nodes of the old tree which are not shared with
+// any other Rope are reused for the new one, as are those kept by Reset, so
+// that recycling a Rope for each new document does not allocate a fresh tree
+// every time.  Any Reader
+// created before the call is invalidated.
+func (r *Rope) SetContent(s string) {
+	if r.frozen {
+		panic(ErrFrozen)
+	}
+
+	length := r.root.length
+	pool := r.root.recycle(r.pool)
+	r.pool = nil
+	r.root = build(&pool, s, fillLength)
+	r.edited(0, length, length)
+}
+
+// Reset empties the Rope, as SetContent("") does, but keeps the nodes of its
+// tree which are not shared with any other Rope, cleared of their content, for
+// the next call to SetContent to reuse.  This suits a Rope which is filled
+// again and again, such as a log buffer; a Rope which is only to be emptied
+// should be replaced instead, so that its nodes can be collected.
+func (r *Rope) Reset() {
+	if r.frozen {
+		panic(ErrFrozen)
+	}
+
+	length := r.root.length
+	kept := len(r.pool)
+	r.pool = r.root.recycle(r.pool)
+	for _, n := range r.pool[kept:] {
+		*n = node{}
+	}
+	r.root = newNode("")
+	r.edited(0, length, length)
+}
+
+// ReplaceRangeDelta returns the change in the rune and byte lengths of the
+// Rope which replacing the runes between start and end with s would make,
+// without making it.  The lengths of the range are found from the counts
+// cached in the tree, so the range is not read.
+func (r *Rope) ReplaceRangeDelta(start, end int, s string) (runeDelta, byteDelta int, err error) {
+	if r == nil {
+		return 0, 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if start < 0 || start > r.root.length {
+		return 0, 0, fmt.Errorf("start %d: %w", start, ErrIndexOutOfRange)
+	}
+
+	if end < 0 || end > r.root.length {
+		return 0, 0, fmt.Errorf("end %d: %w", end, ErrIndexOutOfRange)
+	}
+
+	if start > end {
+		return 0, 0, fmt.Errorf("start %d, end %d: %w", start, end, ErrInvalidRange)
+	}
+
+	runeDelta = utf8.RuneCountInString(s) - (end - start)
+	byteDelta = len(s) - (r.root.byteOffset(end) - r.root.byteOffset(start))
+	return runeDelta, byteDelta, nil
+}
+
+// ReplaceRangeReturning replaces the runes between start and end with s, and
+// returns the runes which were replaced, for recording the edit to be undone.
+// The replaced runes are collected as the edit descends the tree, rather than
+// read beforehand.  If the range is invalid, the Rope is left unchanged.
+func (r *Rope) ReplaceRangeReturning(start, end int, s string) (removed string, err error) {
+	if r == nil {
+		return "", fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return "", ErrFrozen
 	}
-	if end < 0 || end > r.length {
-		return fmt.Errorf("End is not within rope bounds")
+
+	if start < 0 || start > r.root.length {
+		return "", fmt.Errorf("start %d: %w", start, ErrIndexOutOfRange)
+	}
+
+	if end < 0 || end > r.root.length {
+		return "", fmt.Errorf("end %d: %w", end, ErrIndexOutOfRange)
 	}
+
 	if start > end {
-		return fmt.Errorf("Start is greater than end")
+		return "", fmt.Errorf("start %d, end %d: %w", start, end, ErrInvalidRange)
+	}
+
+	if start == end {
+		return "", r.Insert(start, s)
+	}
+
+	if err := r.checkSize(start, end, s); err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	length := r.root.length
+	r.root = r.root.mutable()
+	r.root.alter(start, end, s, &buf)
+	r.edited(start, end, length)
+	return buf.String(), nil
+}
+
+// Revision returns a counter which is advanced by every edit of the Rope,
+// starting from 0 when the Rope is created
+func (r *Rope) Revision() uint64 {
+	return r.revision.Load()
+}
+
+// RuneAt returns the rune at the given rune offset.  The leaf holding it is
+// found from the rune counts of the tree, and only that rune is decoded.  An
+// offset outside the Rope returns ErrIndexOutOfRange.
+func (r *Rope) RuneAt(position int) (rune, error) {
+	if r == nil {
+		return 0, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if position < 0 || position >= r.root.length {
+		return 0, ErrIndexOutOfRange
+	}
+
+	return r.root.runeAt(position), nil
+}
+
+// RuneAtClamped returns the rune at the given rune offset, clamped to the
+// bounds of the Rope, so a negative offset returns the first rune and an
+// offset beyond the end returns the last.  An empty Rope returns
+// utf8.RuneError.
+func (r *Rope) RuneAtClamped(position int) rune {
+	if r.IsEmpty() {
+		return utf8.RuneError
 	}
 
-	return r.remove(start, end)
+	return r.root.runeAt(min(max(position, 0), r.root.length-1))
+}
+
+// Shape returns a canonical description of the structure of the tree, for
+// use in test assertions.  Each leaf is written as its rune length, and each
+// internal node as its two children in parentheses, so a root with a single
+// leaf of 600 runes split in half is written as "(300 300)".  The content of
+// the Rope is not included.
+func (r *Rope) Shape() string {
+	var buf bytes.Buffer
+	r.root.writeShape(&buf)
+	return buf.String()
 }
 
 func (r *Rope) String() string {
 	var buf bytes.Buffer
-	buf.Grow(r.byteLength)
+	buf.Grow(r.root.byteLength)
 	read := r.NewReader()
 	io.Copy(&buf, read)
 	return string(buf.Bytes())
 }
 
-func (r *Rope) adjust() {
-	if r.value != nil {
-		if r.length > splitLength {
-			divide := r.length >> 1
-			offset := r.findByteOffsets(divide)
-			r.left = CreateRope((*r.value)[:offset])
-			r.right = CreateRope((*r.value)[offset:])
-			r.value = nil
-		}
-	} else {
-		if r.length < joinLength {
-			r.join()
-		}
+// StripBOM returns a Rope without a leading UTF-8 byte order mark.  If the
+// Rope does not begin with one, it is returned as-is; otherwise the result is
+// a new Rope which shares its structure with this one.
+func (r *Rope) StripBOM() *Rope {
+	if !r.HasBOM() {
+		return r
 	}
+
+	start := r.root.runeOffset(len(bom))
+	return r.sub(r.root.slice(start, r.root.length))
 }
 
-func (r *Rope) alter(start, end int, value string) error {
-	valueLength := utf8.RuneCountInString(value)
-	valueByteLength := len(value)
-
-	if r.value != nil {
-		var buf bytes.Buffer
-		byteStart := r.findByteOffsets(start)
-		byteEnd := r.findByteOffsets(end)
-		buf.Grow(len(*r.value) - byteEnd + byteStart + valueByteLength)
-		buf.WriteString((*r.value)[0:byteStart])
-		buf.WriteString(value)
-		buf.WriteString((*r.value)[byteEnd:])
-		s := buf.String()
-		r.value = &s
-		r.byteLength -= byteEnd - byteStart - valueByteLength
-		r.length -= end - start - valueLength
-	} else {
-		leftLength := r.left.length
-		leftStart := min(start, leftLength)
-		rightLength := r.right.length
-		rightEnd := max(0, min(end-leftLength, rightLength))
+// Size returns the number of bytes in the Rope, as the size of the content
+// read through OpenReader
+func (r *Rope) Size() int64 {
+	return int64(r.root.byteLength)
+}
+
+// Split divides the Rope at the rune offset i, returning new Ropes holding the
+// runes before and after it.  Like Prefix and Suffix, both share their
+// structure with this Rope, so the cost is proportional to the depth of the
+// tree, and subsequent edits to any of the three Ropes do not affect the
+// others.  An offset outside the Rope returns ErrIndexOutOfRange.
+func (r *Rope) Split(i int) (*Rope, *Rope, error) {
+	if r == nil {
+		return nil, nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if i < 0 || i > r.root.length {
+		return nil, nil, ErrIndexOutOfRange
+	}
+
+	return r.sub(r.root.slice(0, i)), r.sub(r.root.slice(i, r.root.length)), nil
+}
+
+// SubRope returns a new Rope holding the runes between start and end.  Like
+// Prefix, the new Rope shares its structure with this one, and subsequent
+// edits to either Rope do not affect the other.
+func (r *Rope) SubRope(start, end int) (*Rope, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if start < 0 || start > r.root.length {
+		return nil, fmt.Errorf("start %d: %w", start, ErrIndexOutOfRange)
+	}
+
+	if end < 0 || end > r.root.length {
+		return nil, fmt.Errorf("end %d: %w", end, ErrIndexOutOfRange)
+	}
+
+	if start > end {
+		return nil, fmt.Errorf("start %d, end %d: %w", start, end, ErrInvalidRange)
+	}
+
+	return r.sub(r.root.slice(start, end)), nil
+}
+
+// Substring returns the runes between start and end, which are rune offsets
+// as for Insert and Remove.  Only the leaves holding the range are read, and
+// a range within a single leaf is returned without copying.
+func (r *Rope) Substring(start, end int) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("Nil pointer receiver")
+	}
+
+	if start < 0 || start > r.root.length {
+		return "", fmt.Errorf("start %d: %w", start, ErrIndexOutOfRange)
+	}
+
+	if end < 0 || end > r.root.length {
+		return "", fmt.Errorf("end %d: %w", end, ErrIndexOutOfRange)
+	}
+
+	if start > end {
+		return "", fmt.Errorf("start %d, end %d: %w", start, end, ErrInvalidRange)
+	}
+
+	return r.root.substring(start, end), nil
+}
+
+// SubstringClamped returns the runes between start and end, each clamped to
+// the bounds of the Rope.  If start is after end once clamped, the result is
+// empty.
+func (r *Rope) SubstringClamped(start, end int) string {
+	if r.IsEmpty() {
+		return ""
+	}
+
+	start = min(max(start, 0), r.root.length)
+	end = min(max(end, 0), r.root.length)
+	return r.root.substring(start, end)
+}
+
+// Suffix returns a new Rope holding the last n runes of this Rope.  Like
+// Prefix, the new Rope shares its structure with this one.
+func (r *Rope) Suffix(n int) (*Rope, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	if n < 0 || n > r.root.length {
+		return nil, fmt.Errorf("n %d: %w", n, ErrIndexOutOfRange)
+	}
+
+	return r.sub(r.root.slice(r.root.length-n, r.root.length)), nil
+}
+
+// SwapRanges exchanges the runes between aStart and aEnd with those between
+// bStart and bEnd, which may be of different lengths and given in either
+// order, but must not overlap.  As with Move, the Rope is cut and rejoined
+// around the two blocks, so their runes are not copied, and Markers between
+// the start of the first block and the end of the second are invalidated.
+func (r *Rope) SwapRanges(aStart, aEnd, bStart, bEnd int) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
 
-		valueCutoff := findByteOffset(value, min(valueLength, leftLength-leftStart))
+	if r.frozen {
+		return ErrFrozen
+	}
 
-		if leftStart < leftLength {
-			leftEnd := min(end, leftLength)
-			r.left.alter(leftStart, leftEnd, value[:valueCutoff])
+	for _, rg := range []Range{{aStart, aEnd}, {bStart, bEnd}} {
+		if rg.Start < 0 || rg.End > r.root.length {
+			return fmt.Errorf("range [%d, %d): %w", rg.Start, rg.End, ErrIndexOutOfRange)
 		}
-		if rightEnd > 0 || valueCutoff < valueByteLength {
-			rightStart := max(0, min(start-leftLength, rightLength))
-			valueStart := findByteOffset(value, min(valueLength, leftLength-leftStart))
-			r.right.alter(rightStart, rightEnd, value[valueStart:])
+		if rg.Start > rg.End {
+			return fmt.Errorf("range [%d, %d): %w", rg.Start, rg.End, ErrInvalidRange)
 		}
-		r.byteLength = r.left.byteLength + r.right.byteLength
-		r.length = r.left.length + r.right.length
 	}
 
-	r.adjust()
+	if bStart < aStart {
+		aStart, aEnd, bStart, bEnd = bStart, bEnd, aStart, aEnd
+	}
+
+	if aEnd > bStart {
+		return fmt.Errorf("ranges overlap")
+	}
+
+	if aStart == aEnd && bStart == bEnd {
+		return nil
+	}
+
+	length := r.root.length
+	r.root = concat(
+		concat(r.root.slice(0, aStart), r.root.slice(bStart, bEnd)),
+		concat(
+			concat(r.root.slice(aEnd, bStart), r.root.slice(aStart, aEnd)),
+			r.root.slice(bEnd, length),
+		),
+	)
+	r.edited(aStart, bEnd, length)
 	return nil
 }
 
-func (r *Rope) findByteOffsets(position int) int {
-	offset := 0
-	rs := []rune(*r.value)
+// Truncate removes every rune after the first n.  Rather than removing them
+// as Remove does, which visits every leaf in the range, the subtrees after
+// the cut are dropped whole, so only the nodes along the path to it are
+// touched.  An offset outside the Rope returns ErrIndexOutOfRange.
+func (r *Rope) Truncate(n int) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
 
-	for i := 0; i < position; i++ {
-		offset += utf8.RuneLen(rs[i])
+	if r.frozen {
+		return ErrFrozen
 	}
 
-	return offset
-}
+	if n < 0 || n > r.root.length {
+		return ErrIndexOutOfRange
+	}
+
+	if n == r.root.length {
+		return nil
+	}
 
-func (r *Rope) insert(position int, value string) error {
-	if r.value != nil {
-		var buf bytes.Buffer
-		offset := r.findByteOffsets(position)
-		valueLength := utf8.RuneCountInString(value)
-		valueBytesLength := len(value)
-		buf.Grow(r.byteLength + valueBytesLength)
-		buf.WriteString((*r.value)[0:offset])
-		buf.WriteString(value)
-		buf.WriteString((*r.value)[offset:])
-		s := buf.String()
-		r.value = &s
-		r.byteLength += valueBytesLength
-		r.length += valueLength
+	length := r.root.length
+	if n == 0 {
+		r.root = newNode("")
 	} else {
-		leftLength := r.left.length
-		if position < leftLength {
-			r.left.insert(position, value)
-		} else {
-			r.right.insert(position-leftLength, value)
-		}
-		r.byteLength = r.left.byteLength + r.right.byteLength
-		r.length = r.left.length + r.right.length
+		r.root = r.root.truncate(n)
 	}
-	r.adjust()
+	r.edited(n, length, length)
 	return nil
 }
 
-func (r *Rope) join() {
-	c := r.left.byteLength + r.right.byteLength
-	var buf bytes.Buffer
-	buf.Grow(c)
-	io.Copy(&buf, r.left.NewReader())
-	io.Copy(&buf, r.right.NewReader())
-	s := buf.String()
-	r.value = &s
-	r.left = nil
-	r.right = nil
+// Write appends the bytes to the Rope, so that a Rope may be used as an
+// io.Writer
+func (r *Rope) Write(p []byte) (int, error) {
+	if err := r.Append(string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
-func (r *Rope) locate(position int) (*Rope, int) {
-	if r.value != nil {
-		return r, position
+// WriteEscaped writes the content of the Rope to w, passing each rune to the
+// escaper and writing the string it returns in place of the rune, or the rune
+// itself if it returns "".  Runs of runes which need no escaping are written
+// straight from the leaves, so neither the content nor the escaped result is
+// ever held in full.
+func (r *Rope) WriteEscaped(w io.Writer, escaper func(rune) string) error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
 	}
 
-	leftLength := r.left.length
-	if position < leftLength {
-		return r.left.locate(position)
+	bw := bufio.NewWriter(w)
+	var err error
+	r.root.walk(func(value string) bool {
+		plain := 0
+		for i, ru := range value {
+			escaped := escaper(ru)
+			if escaped == "" {
+				continue
+			}
+			bw.WriteString(value[plain:i])
+			if _, err = bw.WriteString(escaped); err != nil {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(value[i:])
+			plain = i + size
+		}
+		_, err = bw.WriteString(value[plain:])
+		return err == nil
+	})
+	if err != nil {
+		return err
 	}
+	return bw.Flush()
+}
 
-	return r.right.locate(position - leftLength)
+// WriteTo writes the content of the Rope to w leaf by leaf, so that a Rope
+// is an io.WriterTo, and returns the number of bytes written.  Each leaf is
+// written directly, without a copy buffer.  If w fails or writes short, the
+// bytes written so far are returned with the error.
+func (r *Rope) WriteTo(w io.Writer) (int64, error) {
+	n, err := r.root.writeTo(w)
+	return int64(n), err
 }
 
-func (r *Rope) rebuild() {
-	if r.value == nil {
-		r.join()
-		r.adjust()
-	}
+// WriteToBuilder appends the content of the Rope to the builder, growing it
+// once by ByteLength first.  Unlike b.WriteString(r.String()), the leaves are
+// copied straight into the builder without an intermediate string.
+func (r *Rope) WriteToBuilder(b *strings.Builder) {
+	b.Grow(r.root.byteLength)
+	r.root.walk(func(value string) bool {
+		b.WriteString(value)
+		return true
+	})
 }
 
-func (r *Rope) remove(start, end int) error {
-	if r.value != nil {
-		var buf bytes.Buffer
-		byteStart := r.findByteOffsets(start)
-		byteEnd := r.findByteOffsets(end)
-		buf.Grow(len(*r.value) - byteEnd + byteStart)
-		buf.WriteString((*r.value)[0:byteStart])
-		buf.WriteString((*r.value)[byteEnd:])
-		s := buf.String()
-		r.value = &s
-		r.byteLength -= byteEnd - byteStart
-		r.length -= end - start
-	} else {
-		leftLength := r.left.length
-		leftStart := min(start, leftLength)
-		rightLength := r.right.length
-		rightEnd := max(0, min(end-leftLength, rightLength))
-		if leftStart < leftLength {
-			leftEnd := min(end, leftLength)
-			r.left.remove(leftStart, leftEnd)
-		}
-		if rightEnd > 0 {
-			rightStart := max(0, min(start-leftLength, rightLength))
-			r.right.remove(rightStart, rightEnd)
+// alter replaces the runes between start and end with the value, which have
+// already been checked
+func (r *Rope) alter(start, end int, value string) {
+	length := r.root.length
+	if start == end {
+		// This is a pure insert
+		if value == "" {
+			// No-op; nothing to insert
+			return
 		}
-		r.byteLength = r.left.byteLength + r.right.byteLength
-		r.length = r.left.length + r.right.length
+
+		r.root = r.root.mutable()
+		r.root.insert(start, value)
+
+	} else if value == "" {
+		// This is a pure remove
+		r.root = r.root.mutable()
+		r.root.remove(start, end)
+
+	} else {
+		r.root = r.root.mutable()
+		r.root.alter(start, end, value, nil)
+	}
+
+	r.edited(start, end, length)
+}
+
+// balance rebuilds the part of the tree made too deep by an edit, unless
+// automatic balancing has been disabled
+func (r *Rope) balance() {
+	if !r.options.DisableAutoBalance && r.root.unbalanced() {
+		r.root = r.root.mutable()
+		r.root.balance()
+	}
+}
+
+// changed records an edit of the Rope's content, which replaced the runes
+// between start and end of a Rope of the given length
+func (r *Rope) changed(start, end, length int) {
+	inserted := r.root.length - length + end - start
+	r.moveMarkers(start, end, inserted)
+	r.revision.Add(1)
+	r.notify(start, end, inserted)
+}
+
+// checkSize returns ErrTooLarge if replacing the runes between start and end
+// with the value would grow the Rope beyond its maximum size
+func (r *Rope) checkSize(start, end int, value string) error {
+	if r.options.MaxBytes == 0 {
+		return nil
 	}
 
-	r.adjust()
+	return r.checkGrowth(len(value) - (r.root.byteOffset(end) - r.root.byteOffset(start)))
+}
+
+// checkGrowth returns ErrTooLarge if growing the Rope by the given number of
+// bytes would take it beyond its maximum size
+func (r *Rope) checkGrowth(growth int) error {
+	if r.options.MaxBytes != 0 && growth > 0 && r.root.byteLength+growth > r.options.MaxBytes {
+		return ErrTooLarge
+	}
 	return nil
 }
 
-// Reader implements io.Reader and io.WriterTo for a Rope rope
+// edited completes every edit of the Rope's content which may have changed
+// the structure of the tree, as changed describes
+func (r *Rope) edited(start, end, length int) {
+	r.spine = nil
+	r.balance()
+	r.changed(start, end, length)
+}
+
+// insertNode inserts the runes of the subtree at the given offset, joining
+// it into the tree rather than copying it.  Any part of the subtree which is
+// reachable from elsewhere must already be marked as shared.
+func (r *Rope) insertNode(position int, n *node) {
+	length := r.root.length
+	r.root = concat(concat(r.root.slice(0, position), n), r.root.slice(position, length))
+	r.edited(position, position, length)
+}
+
+// sub creates a Rope around a subtree derived from this Rope, with the same
+// options
+func (r *Rope) sub(root *node) *Rope {
+	return &Rope{root: root, options: r.options}
+}
+
+// Reader implements io.Reader, io.Seeker, io.WriterTo and io.Closer for a
+// Rope.  Its position is a byte offset into the Rope.  A Reader reads from
+// the current content of its Rope, so after an edit it continues from the
+// same byte offset in the edited content.
 type Reader struct {
 	pos int
 	r   *Rope
+
+	// leaf caches the content of the leaf last read by ReadRune, which
+	// begins at leafStart, as of the given revision of the Rope
+	leaf      string
+	leafStart int
+	revision  uint64
+}
+
+// Close releases the Reader.  The content of a Rope is held in memory or
+// mapped for the life of the Rope, so there is nothing to release.
+func (read *Reader) Close() error {
+	return nil
 }
 
 func (read *Reader) Read(p []byte) (n int, err error) {
-	if read.pos == read.r.length {
+	if read.pos >= read.r.root.byteLength {
 		return 0, io.EOF
 	}
 
-	node, offset := read.r.locate(read.pos)
+	node, offset := read.r.root.locateByte(read.pos)
 
-	copied := copy(p, []byte(string([]rune(*node.value)[offset:])))
+	copied := copy(p, (*node.value)[offset:])
 	read.pos += copied
 	return copied, nil
 }
 
-// WriteTo writes the contents of a Rope to the provided io.Writer
-func (read *Reader) WriteTo(w io.Writer) (int64, error) {
-	n, err := read.writeNodeTo(read.r, w)
-	return int64(n), err
-}
-
-func (read *Reader) writeNodeTo(r *Rope, w io.Writer) (int, error) {
-	if r.value != nil {
-		copied, err := io.WriteString(w, *r.value)
-		if copied != len(*r.value) && err == nil {
-			err = io.ErrShortWrite
-		}
-		return copied, err
+// ReadRune reads the UTF-8 encoded rune at the current position, so that a
+// Reader may be used as an io.RuneReader, such as with the regexp package.
+// The leaf being read is kept between calls, so reading rune by rune does not
+// search the tree for each one.
+func (read *Reader) ReadRune() (ru rune, size int, err error) {
+	if read.pos >= read.r.root.byteLength {
+		return 0, 0, io.EOF
 	}
 
-	var err error
-	var n, m int
-
-	n, err = read.writeNodeTo(r.left, w)
-	if err != nil {
-		return n, err
+	revision := read.r.revision.Load()
+	offset := read.pos - read.leafStart
+	if read.revision != revision || offset < 0 || offset >= len(read.leaf) {
+		node, nodeOffset := read.r.root.locateByte(read.pos)
+		read.leaf, read.leafStart, read.revision = *node.value, read.pos-nodeOffset, revision
+		offset = nodeOffset
 	}
 
-	m, err = read.writeNodeTo(r.right, w)
-
-	return int(n + m), err
+	ru, size = utf8.DecodeRuneInString(read.leaf[offset:])
+	read.pos += size
+	return ru, size, nil
 }
 
-func findByteOffset(s string, position int) int {
-	offset := 0
-	for i := 0; i < position; i++ {
-		r, n := utf8.DecodeRuneInString(s[offset:])
-		if r == utf8.RuneError {
-			return -1
-		}
-		offset += n
+// Seek sets the byte offset for the next Read or WriteTo, interpreted
+// according to whence as described by io.Seeker
+func (read *Reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = int64(read.pos) + offset
+	case io.SeekEnd:
+		pos = int64(read.r.root.byteLength) + offset
+	default:
+		return 0, fmt.Errorf("invalid whence")
 	}
-	return offset
-}
 
-func min(a, b int) int {
-	if a < b {
-		return a
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position")
 	}
-	return b
+
+	read.pos = int(pos)
+	return pos, nil
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// WriteTo writes the remaining contents of a Rope to the provided io.Writer
+func (read *Reader) WriteTo(w io.Writer) (int64, error) {
+	if read.pos == 0 {
+		n, err := read.r.root.writeTo(w)
+		read.pos = n
+		return int64(n), err
+	}
+
+	written := 0
+	for read.pos < read.r.root.byteLength {
+		node, offset := read.r.root.locateByte(read.pos)
+		n, err := io.WriteString(w, (*node.value)[offset:])
+		read.pos += n
+		written += n
+		if err != nil {
+			return int64(written), err
+		}
 	}
-	return b
+	return int64(written), nil
 }
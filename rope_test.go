@@ -2,13 +2,25 @@ package rope
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
+	"unicode"
 	"unicode/utf8"
+	"unsafe"
 )
 
 const (
@@ -62,6 +74,98 @@ func Test_Insert(t *testing.T) {
 	}
 }
 
+func Test_BOM(t *testing.T) {
+	loopTest(t, "BOM", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+
+		r := CreateRope(init)
+		if r.HasBOM() {
+			t.Fatal("Rope without BOM reports one")
+		}
+		if r.StripBOM() != r {
+			t.Fatal("Stripping a rope without BOM did not return the same rope")
+		}
+
+		r = CreateRope(bom + init)
+		if !r.HasBOM() {
+			t.Fatal("Rope with BOM does not report one")
+		}
+		stripped := r.StripBOM()
+		if stripped.HasBOM() {
+			t.Fatal("Stripped rope still reports BOM")
+		}
+		if stripped.Length() != stringSize.size || stripped.ByteLength() != len(init) {
+			t.Fatalf("Incorrect lengths: expected %d/%d, got %d/%d", stringSize.size, len(init), stripped.Length(), stripped.ByteLength())
+		}
+		if result := stripped.String(); result != init {
+			t.Fatalf("StripBOM failed:\nExpected:\n'%+q'\nGet:\n'%+q'", init, result)
+		}
+		if result := r.String(); result != bom+init {
+			t.Fatal("Original rope was altered")
+		}
+	})
+
+	for _, s := range []string{"", "\xEF", "\xEF\xBB", "\xEF\xBBa", "a\xEF\xBB\xBF"} {
+		if CreateRope(s).HasBOM() {
+			t.Fatalf("Rope %q reports BOM", s)
+		}
+	}
+}
+
+func Test_BOM_Across_Leaves(t *testing.T) {
+	for split := 1; split < len(bom); split++ {
+		n := &node{right: newNode(bom[split:] + "abc"), left: newNode(bom[:split])}
+		n.recount()
+		r := &Rope{root: n}
+		if !r.HasBOM() {
+			t.Fatalf("Rope with BOM split at %d does not report one", split)
+		}
+		if result := r.StripBOM().String(); result != "abc" {
+			t.Fatalf("StripBOM with BOM split at %d failed: got %q", split, result)
+		}
+	}
+}
+
+func Test_IsEmpty(t *testing.T) {
+	var nilRope *Rope
+	if !nilRope.IsEmpty() {
+		t.Fatal("Nil rope is not empty")
+	}
+
+	r := CreateRope("")
+	if !r.IsEmpty() {
+		t.Fatal("Rope created from empty string is not empty")
+	}
+	if r.root.value == nil || *r.root.value != "" {
+		t.Fatal("Empty rope is not represented by an empty leaf")
+	}
+
+	loopTest(t, "IsEmpty", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		if r.IsEmpty() {
+			t.Fatal("Rope with content is empty")
+		}
+
+		p, _ := r.Prefix(0)
+		if !p.IsEmpty() {
+			t.Fatal("Empty prefix is not empty")
+		}
+
+		r.Remove(0, r.Length())
+		if !r.IsEmpty() {
+			t.Fatal("Rope with all content removed is not empty")
+		}
+		if r.root.value == nil || *r.root.value != "" {
+			t.Fatal("Emptied rope is not represented by an empty leaf")
+		}
+
+		r.Insert(0, "a")
+		if r.IsEmpty() {
+			t.Fatal("Rope with content is empty")
+		}
+	})
+}
+
 func Test_Create(t *testing.T) {
 	loopTest(t, "Create", func(t *testing.T, charSet charSet, stringSize stringSize) {
 		init := charSet.generator(stringSize.size)
@@ -82,6 +186,523 @@ func Test_Create(t *testing.T) {
 	})
 }
 
+func Test_Lengths(t *testing.T) {
+	loopTest(t, "Lengths", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		r.Insert(stringSize.size/2, "🐿")
+
+		runes, bytes := r.Lengths()
+		if runes != stringSize.size+1 || bytes != len(init)+len("🐿") {
+			t.Fatalf("Incorrect lengths: expected %d/%d, got %d/%d", stringSize.size+1, len(init)+len("🐿"), runes, bytes)
+		}
+	})
+}
+
+func Test_CreateRopeFromBytes(t *testing.T) {
+	loopTest(t, "CreateRopeFromBytes", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		expected := charSet.generator(stringSize.size * 10)
+		data := []byte(expected)
+		r := CreateRopeFromBytes(data)
+		if r.String() != expected {
+			t.Fatal("Incorrect content")
+		}
+		if r.Length() != utf8.RuneCountInString(expected) || r.ByteLength() != len(expected) {
+			t.Fatalf("Incorrect lengths: got %d/%d", r.Length(), r.ByteLength())
+		}
+		assertLogarithmicDepth(t, r)
+
+		// The leaves refer into data, which edits leave alone
+		n := r.root
+		for n.value == nil {
+			n = n.left
+		}
+		if unsafe.StringData(*n.value) != &data[0] {
+			t.Fatal("First leaf does not refer into data")
+		}
+		r.Remove(0, 5)
+		r.Insert(1, "x")
+		if string(data) != expected {
+			t.Fatal("Edit wrote to data")
+		}
+	})
+
+	if r := CreateRopeFromBytes(nil); !r.IsEmpty() {
+		t.Fatal("Nil slice did not create an empty rope")
+	}
+}
+
+func Test_CreateRopeFromReader(t *testing.T) {
+	loopTest(t, "CreateRopeFromReader", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		expected := charSet.generator(stringSize.size * 10)
+		for _, reader := range []io.Reader{
+			strings.NewReader(expected),
+			iotest.OneByteReader(strings.NewReader(expected)),
+			iotest.HalfReader(strings.NewReader(expected)),
+		} {
+			r, err := CreateRopeFromReader(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != expected {
+				t.Fatal("Incorrect content")
+			}
+			if r.Length() != utf8.RuneCountInString(expected) || r.ByteLength() != len(expected) {
+				t.Fatalf("Incorrect lengths: got %d/%d", r.Length(), r.ByteLength())
+			}
+			assertLogarithmicDepth(t, r)
+		}
+	})
+
+	if r, err := CreateRopeFromReader(iotest.TimeoutReader(strings.NewReader("abc"))); err == nil || r != nil {
+		t.Fatal("Expected error from reader")
+	}
+}
+
+func Test_Repeat(t *testing.T) {
+	for _, s := range []string{"x", "ab🐿\n", generateUnicodeString(600)} {
+		for _, count := range []int{0, 1, 2, 7, 100, 1234} {
+			r := Repeat(s, count)
+			expected := strings.Repeat(s, count)
+			if r.String() != expected {
+				t.Fatalf("Incorrect content for %d copies of %q", count, s)
+			}
+			if r.LineCount() != strings.Count(expected, "\n")+1 {
+				t.Fatalf("Incorrect line count for %d copies of %q", count, s)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+
+			// Each copy shares its nodes with the others, so an edit of one
+			// must leave the rest intact
+			if count > 1 {
+				position := r.Length() / 2
+				r.Insert(position, "Ω")
+				runes := []rune(expected)
+				if r.String() != string(runes[:position])+"Ω"+string(runes[position:]) {
+					t.Fatalf("Edit of %d copies of %q changed other copies", count, s)
+				}
+			}
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() { Repeat("pattern\n", 1<<27) })
+	if allocs > 200 {
+		t.Fatalf("Repeat made %g allocations", allocs)
+	}
+
+	for _, count := range []int{-1, math.MaxInt} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected panic for count %d", count)
+				}
+			}()
+			Repeat("ab", count)
+		}()
+	}
+}
+
+func Test_CreateRopeFromSeq(t *testing.T) {
+	loopTest(t, "CreateRopeFromSeq", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		pieces := make([]string, stringSize.size)
+		for i := range pieces {
+			pieces[i] = charSet.generator(i % 5)
+		}
+		expected := strings.Join(pieces, "")
+
+		r := CreateRopeFromSeq(slices.Values(pieces))
+		if r.String() != expected {
+			t.Fatal("Incorrect content")
+		}
+		if r.Length() != utf8.RuneCountInString(expected) || r.ByteLength() != len(expected) {
+			t.Fatalf("Incorrect lengths: got %d/%d", r.Length(), r.ByteLength())
+		}
+		assertLogarithmicDepth(t, r)
+	})
+
+	r := CreateRopeFromSeq(slices.Values([]string{}))
+	if !r.IsEmpty() || r.root.value == nil {
+		t.Fatal("Empty sequence did not create an empty rope")
+	}
+}
+
+func Test_CreateRopeFromSeq_Tiny(t *testing.T) {
+	r := CreateRopeFromSeq(func(yield func(string) bool) {
+		for i := 0; i < 100000; i++ {
+			if !yield(string(rune('a' + i%26))) {
+				return
+			}
+		}
+	})
+	if r.Length() != 100000 {
+		t.Fatalf("Incorrect length: expected 100000, got %d", r.Length())
+	}
+	assertLogarithmicDepth(t, r)
+
+	leaves := r.root.leaves(nil, false)
+	for _, leaf := range leaves {
+		if leaf.length < joinLength || leaf.length > splitLength {
+			t.Fatalf("Leaf of %d runes", leaf.length)
+		}
+	}
+}
+
+func Test_InLeaf(t *testing.T) {
+	loopTest(t, "InLeaf", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		var starts []int
+		offset := 0
+		for _, leaf := range r.root.leaves(nil, false) {
+			starts = append(starts, offset)
+			offset += leaf.length
+		}
+
+		for start := 0; start < len(runes); start += 37 {
+			for _, end := range []int{start, start + 1, start + 40, len(runes)} {
+				if end > len(runes) {
+					continue
+				}
+				b, ok := r.InLeaf(start, end)
+				within := true
+				for _, boundary := range starts {
+					if start < boundary && boundary < end {
+						within = false
+					}
+				}
+				if ok != within {
+					t.Fatalf("Incorrect result for %d-%d: expected %t, got %t", start, end, within, ok)
+				}
+				if ok && string(b) != string(runes[start:end]) {
+					t.Fatalf("Incorrect bytes for %d-%d", start, end)
+				}
+			}
+		}
+
+		for _, span := range [][2]int{{-1, 1}, {0, len(runes) + 1}, {2, 1}} {
+			if _, ok := r.InLeaf(span[0], span[1]); ok {
+				t.Fatalf("Invalid range %v reported in leaf", span)
+			}
+		}
+	})
+}
+
+func Test_Move(t *testing.T) {
+	loopTest(t, "Move", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		length := len(runes)
+		join := func(parts ...[]rune) string {
+			var b strings.Builder
+			for _, part := range parts {
+				b.WriteString(string(part))
+			}
+			return b.String()
+		}
+
+		tests := []struct {
+			name            string
+			start, end, dst int
+			expected        string
+		}{
+			{"backward", length / 2, length/2 + 40, 10, join(runes[:10], runes[length/2:length/2+40], runes[10:length/2], runes[length/2+40:])},
+			{"forward", 10, 60, length - 5, join(runes[:10], runes[60:length-5], runes[10:60], runes[length-5:])},
+			{"to-start", length - 30, length, 0, join(runes[length-30:], runes[:length-30])},
+			{"to-end", 0, 30, length, join(runes[30:], runes[:30])},
+			{"own-end", 10, 60, 60, string(runes)},
+		}
+
+		for _, tc := range tests {
+			r := CreateRope(string(runes))
+			if err := r.Move(tc.start, tc.end, tc.dst); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != tc.expected {
+				t.Fatalf("Move %s failed", tc.name)
+			}
+			if r.Length() != length || r.ByteLength() != len(tc.expected) {
+				t.Fatalf("Incorrect lengths after move %s", tc.name)
+			}
+			assertLogarithmicDepth(t, r)
+		}
+	})
+}
+
+func Test_Move_Invalid(t *testing.T) {
+	r := CreateRope("abcdef")
+	for _, tc := range [][3]int{{-1, 2, 4}, {2, 7, 0}, {1, 4, 7}} {
+		if err := r.Move(tc[0], tc[1], tc[2]); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatalf("Expected ErrIndexOutOfRange for %v, got %v", tc, err)
+		}
+	}
+	if err := r.Move(3, 2, 0); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("Expected ErrInvalidRange, got %v", err)
+	}
+	if err := r.Move(1, 4, 2); err == nil {
+		t.Fatal("Expected error for dest within the range")
+	}
+	if r.String() != "abcdef" {
+		t.Fatal("Invalid move changed the rope")
+	}
+}
+
+func Test_SwapRanges(t *testing.T) {
+	loopTest(t, "SwapRanges", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size) + "\n" + charSet.generator(stringSize.size))
+		length := len(runes)
+		join := func(parts ...[]rune) string {
+			var b strings.Builder
+			for _, part := range parts {
+				b.WriteString(string(part))
+			}
+			return b.String()
+		}
+
+		tests := []struct {
+			name                       string
+			aStart, aEnd, bStart, bEnd int
+			expected                   string
+		}{
+			{"longer-first", 10, length / 2, length - 30, length - 20, join(runes[:10], runes[length-30:length-20], runes[length/2:length-30], runes[10:length/2], runes[length-20:])},
+			{"shorter-first", 0, 5, 50, length, join(runes[50:], runes[5:50], runes[:5])},
+			{"reversed", length - 30, length - 20, 10, length / 2, join(runes[:10], runes[length-30:length-20], runes[length/2:length-30], runes[10:length/2], runes[length-20:])},
+			{"adjacent", 10, 20, 20, 100, join(runes[:10], runes[20:100], runes[10:20], runes[100:])},
+			{"empty", 10, 10, 30, 40, join(runes[:10], runes[30:40], runes[10:30], runes[40:])},
+		}
+
+		for _, tc := range tests {
+			r := CreateRope(string(runes))
+			if err := r.SwapRanges(tc.aStart, tc.aEnd, tc.bStart, tc.bEnd); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != tc.expected {
+				t.Fatalf("Swap %s failed", tc.name)
+			}
+			if r.Length() != length || r.ByteLength() != len(tc.expected) || r.LineCount() != 2 {
+				t.Fatalf("Incorrect counts after swap %s", tc.name)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+		}
+	})
+
+	r := CreateRope("abcdef")
+	for _, tc := range []struct {
+		ranges   [4]int
+		expected error
+	}{
+		{[4]int{-1, 2, 3, 4}, ErrIndexOutOfRange},
+		{[4]int{0, 2, 3, 7}, ErrIndexOutOfRange},
+		{[4]int{2, 1, 3, 4}, ErrInvalidRange},
+	} {
+		if err := r.SwapRanges(tc.ranges[0], tc.ranges[1], tc.ranges[2], tc.ranges[3]); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for %v, got %v", tc.expected, tc.ranges, err)
+		}
+	}
+	for _, tc := range [][4]int{{0, 3, 2, 4}, {2, 4, 0, 3}} {
+		if err := r.SwapRanges(tc[0], tc[1], tc[2], tc[3]); err == nil {
+			t.Fatalf("Expected error for overlapping ranges %v", tc)
+		}
+	}
+	if r.String() != "abcdef" || r.Revision() != 0 {
+		t.Fatal("Invalid swap changed the rope")
+	}
+}
+
+func Test_LeafBoundaries(t *testing.T) {
+	loopTest(t, "LeafBoundaries", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		r.Insert(stringSize.size/3, charSet.generator(300))
+
+		boundaries := r.LeafBoundaries()
+		leaves := r.root.leaves(nil, false)
+		if len(boundaries) != len(leaves) || boundaries[0] != 0 {
+			t.Fatalf("Incorrect boundaries: %v", boundaries)
+		}
+
+		content := r.String()
+		for i, leaf := range leaves {
+			if !strings.HasPrefix(content[boundaries[i]:], *leaf.value) {
+				t.Fatalf("Leaf %d does not begin at %d", i, boundaries[i])
+			}
+		}
+	})
+
+	if boundaries := CreateRope("").LeafBoundaries(); !reflect.DeepEqual(boundaries, []int{0}) {
+		t.Fatalf("Incorrect boundaries for empty rope: %v", boundaries)
+	}
+}
+
+func Test_Duplicate(t *testing.T) {
+	loopTest(t, "Duplicate", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		length := len(runes)
+
+		for _, tc := range [][3]int{{0, length / 2, length}, {length / 2, length, 0}, {10, 50, 30}, {0, length, length / 3}} {
+			start, end, dest := tc[0], tc[1], tc[2]
+			r := CreateRope(string(runes))
+			if err := r.Duplicate(start, end, dest); err != nil {
+				t.Fatal(err)
+			}
+			expected := string(runes[:dest]) + string(runes[start:end]) + string(runes[dest:])
+			if r.String() != expected {
+				t.Fatalf("Duplicate %v failed", tc)
+			}
+			assertLogarithmicDepth(t, r)
+
+			// Editing within each copy must leave the other intact.
+			r.Alter(dest, dest+1, "Ω")
+			if s := r.String(); s[:len(string(runes[:dest]))] != string(runes[:dest]) {
+				t.Fatalf("Edit of copy changed content before it")
+			}
+			copyEnd := dest + end - start
+			if got := string([]rune(r.String())[dest+1 : copyEnd]); got != string(runes[start+1:end]) {
+				t.Fatalf("Edit of copy corrupted it")
+			}
+			r.Insert(0, "x")
+			if got := string([]rune(r.String())[dest+2 : copyEnd+1]); got != string(runes[start+1:end]) {
+				t.Fatalf("Edit of original corrupted the copy")
+			}
+		}
+	})
+}
+
+func Test_Duplicate_Invalid(t *testing.T) {
+	r := CreateRopeWithOptions("abcdef", Options{MaxBytes: 10})
+	for _, tc := range [][3]int{{-1, 2, 4}, {2, 7, 0}, {1, 4, 7}} {
+		if err := r.Duplicate(tc[0], tc[1], tc[2]); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatalf("Expected ErrIndexOutOfRange for %v, got %v", tc, err)
+		}
+	}
+	if err := r.Duplicate(3, 2, 0); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("Expected ErrInvalidRange, got %v", err)
+	}
+	if err := r.Duplicate(0, 6, 0); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if r.String() != "abcdef" {
+		t.Fatal("Invalid duplicate changed the rope")
+	}
+}
+
+func Test_InsertRope(t *testing.T) {
+	loopTest(t, "InsertRope", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		otherInit := charSet.generator(stringSize.size * 3)
+
+		for _, position := range []int{0, len(runes) / 2, len(runes)} {
+			r := CreateRope(string(runes))
+			other := CreateRope(otherInit)
+			if err := r.InsertRope(position, other); err != nil {
+				t.Fatal(err)
+			}
+			expected := string(runes[:position]) + otherInit + string(runes[position:])
+			if r.String() != expected {
+				t.Fatalf("InsertRope at %d failed", position)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+
+			r.Insert(position+1, "x")
+			other.Remove(0, 10)
+			if r.String() != string(runes[:position])+string([]rune(otherInit)[:1])+"x"+string([]rune(otherInit)[1:])+string(runes[position:]) {
+				t.Fatal("Edit of the other rope changed the result")
+			}
+			if other.String() != string([]rune(otherInit)[10:]) {
+				t.Fatal("Edit of the result changed the other rope")
+			}
+		}
+	})
+
+	r := CreateRopeWithOptions("abcdef", Options{MaxBytes: 10})
+	if err := r.InsertRope(7, CreateRope("x")); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if err := r.InsertRope(0, CreateRope("xxxxx")); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if err := r.InsertRope(0, nil); err != nil || r.String() != "abcdef" {
+		t.Fatal("Insert of nil rope changed the rope")
+	}
+	if err := r.InsertRope(3, r); err == nil {
+		t.Fatal("Expected ErrTooLarge for insert of the rope into itself")
+	}
+	r = CreateRope("abc")
+	if err := r.InsertRope(1, r); err != nil || r.String() != "aabcbc" {
+		t.Fatalf("Incorrect insert of the rope into itself: %q, %v", r.String(), err)
+	}
+}
+
+func Benchmark_Duplicate(b *testing.B) {
+	init := generateASCIIString(200000)
+	tests := []struct {
+		name      string
+		duplicate func(r *Rope)
+	}{
+		{"Duplicate", func(r *Rope) { r.Duplicate(0, 100000, 200000) }},
+		{"SubstringInsert", func(r *Rope) { r.Insert(200000, r.SubstringClamped(0, 100000)) }},
+	}
+
+	r := CreateRope(init)
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p, _ := r.Prefix(r.Length())
+				tc.duplicate(p)
+			}
+		})
+	}
+}
+
+func Test_CopyRange(t *testing.T) {
+	loopTest(t, "CopyRange", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		dst := make([]byte, 4*len(runes))
+
+		for _, span := range [][2]int{{0, 0}, {0, 1}, {5, len(runes) - 50}, {0, len(runes)}, {len(runes) - 10, len(runes)}} {
+			expected := string(runes[span[0]:span[1]])
+			n, err := r.CopyRange(dst, span[0], span[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(expected) || string(dst[:n]) != expected {
+				t.Fatalf("Incorrect copy of %v", span)
+			}
+
+			if len(expected) > 0 {
+				if _, err := r.CopyRange(dst[:len(expected)-1], span[0], span[1]); err == nil {
+					t.Fatalf("Expected error for short dst copying %v", span)
+				}
+			}
+		}
+
+		allocs := testing.AllocsPerRun(10, func() {
+			r.CopyRange(dst, 5, len(runes)-5)
+		})
+		if allocs != 0 {
+			t.Fatalf("CopyRange allocated %f times", allocs)
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		start, end int
+		expected   error
+	}{{-1, 1, ErrIndexOutOfRange}, {0, 4, ErrIndexOutOfRange}, {2, 1, ErrInvalidRange}} {
+		if _, err := r.CopyRange(make([]byte, 10), tc.start, tc.end); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+}
+
 func Test_Alter(t *testing.T) {
 	alters := []alterSetIn{
 		{"from-leaf-remove-0-9", 100, 0, 10, 0},
@@ -137,6 +758,8 @@ func Test_Alter(t *testing.T) {
 		{"from-node-to-span-shrink-right", 600, 295, 305, 7},
 		{"from-node-to-span-replace", 600, 295, 305, 10},
 		{"from-node-to-span-grow", 600, 295, 305, 15},
+		{"from-node-to-left-grow-past-left-5-14", 600, 5, 15, 400},
+		{"from-node-to-left-insert-past-left-5", 600, 5, 5, 400},
 	}
 	loopAlterTest(t, "WAT", alters, func(t *testing.T, charSet charSet, alterSet *alterSet) {
 		init := alterSet.x1 + alterSet.x2 + alterSet.x3
@@ -174,6 +797,27 @@ func Test_Alter(t *testing.T) {
 	})
 }
 
+func Test_Alter_Invalid(t *testing.T) {
+	r := CreateRope("abcdef")
+	revision := r.Revision()
+	tests := []struct {
+		start, end int
+		expected   error
+	}{
+		{-1, 2, ErrIndexOutOfRange},
+		{2, 7, ErrIndexOutOfRange},
+		{4, 2, ErrInvalidRange},
+	}
+	for _, tc := range tests {
+		if err := r.Alter(tc.start, tc.end, "xyz"); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+	if r.String() != "abcdef" || r.Revision() != revision {
+		t.Fatalf("Failed Alter changed the rope: %q", r.String())
+	}
+}
+
 func Test_Insert_Small_To_Beginning(t *testing.T) {
 	loopTest(t, "Insert-To-Middle", func(t *testing.T, charSet charSet, stringSize stringSize) {
 		init := charSet.generator(stringSize.size)
@@ -270,25 +914,1301 @@ func Test_Insert_Large_To_Beginning(t *testing.T) {
 	})
 }
 
-func Test_Reader(t *testing.T) {
-	loopTest(t, "Reader", func(t *testing.T, charSet charSet, stringSize stringSize) {
-		init := charSet.generator(stringSize.size)
+func Test_Insert_Bulk(t *testing.T) {
+	charSets := []charSet{
+		{"ASCII", generateASCIIString},
+		{"Unicode", generateUnicodeString},
+	}
+	positions := []string{"Beginning", "Middle", "End"}
 
-		var buf bytes.Buffer
-		buf.Grow(len(init))
+	for _, charSet := range charSets {
+		for i, position := range positions {
+			t.Run(fmt.Sprintf("%s-%s", charSet.name, position), func(t *testing.T) {
+				init := charSet.generator(1000)
+				x := charSet.generator(100000)
+				r := CreateRope(init)
+
+				offset := i * 500
+				if err := r.Insert(offset, x); err != nil {
+					t.Fatal(err)
+				}
 
-		r := CreateRope(init)
-		reader := r.NewReader()
+				runes := []rune(init)
+				expected := string(runes[:offset]) + x + string(runes[offset:])
+				if r.Length() != 101000 {
+					t.Fatalf("Incorrect length: expected %d, got %d", 101000, r.Length())
+				}
+				if r.ByteLength() != len(expected) {
+					t.Fatalf("Incorrect byte length: expected %d, got %d", len(expected), r.ByteLength())
+				}
+				if result := r.String(); result != expected {
+					t.Fatalf("Insert failed:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+				}
+				assertLogarithmicDepth(t, r)
+			})
+		}
+	}
+}
 
-		io.Copy(&buf, reader)
+func Test_InsertAtAll(t *testing.T) {
+	loopTest(t, "InsertAtAll", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		positions := []int{stringSize.size, 0, stringSize.size / 2, 7, stringSize.size / 2, stringSize.size - 1}
+		value := charSet.generator(5)
+		m, _ := r.Mark(stringSize.size / 2)
 
-		result := string(buf.Bytes())
-		if strings.Compare(result, init) != 0 {
+		if err := r.InsertAtAll(positions, value); err != nil {
+			t.Fatal(err)
+		}
+
+		var expected strings.Builder
+		for i := 0; i <= len(runes); i++ {
+			for _, position := range positions {
+				if position == i {
+					expected.WriteString(value)
+				}
+			}
+			if i < len(runes) {
+				expected.WriteRune(runes[i])
+			}
+		}
+		if r.String() != expected.String() {
+			t.Fatalf("Incorrect result:\nExpected:\n%s\nGot:\n%s", expected.String(), r.String())
+		}
+		// The marker stays before the runes inserted at its own position
+		if position, ok := m.Position(); !ok || position != stringSize.size/2+10 {
+			t.Fatalf("Incorrect marker position: expected %d, got %d", stringSize.size/2+10, position)
+		}
+		if r.Revision() != 1 {
+			t.Fatalf("Expected a single revision, got %d", r.Revision())
+		}
+		assertLogarithmicDepth(t, r)
+	})
+
+	r := CreateRopeWithOptions("abc", Options{MaxBytes: 6})
+	for _, positions := range [][]int{{0, 4}, {-1}} {
+		if err := r.InsertAtAll(positions, "x"); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatalf("Expected ErrIndexOutOfRange for %v, got %v", positions, err)
+		}
+	}
+	if err := r.InsertAtAll([]int{0, 1, 2, 3}, "x"); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if r.String() != "abc" || r.Revision() != 0 {
+		t.Fatal("Failed insert changed the rope")
+	}
+}
+
+func Test_InsertIf(t *testing.T) {
+	autoPair := func(before, after rune, ok bool) bool {
+		return after == -1 || unicode.IsSpace(after)
+	}
+
+	r := CreateRope("(a) (b")
+	if err := r.InsertIf(1, ")", autoPair); err != ErrSkipped {
+		t.Fatalf("Expected ErrSkipped, got %v", err)
+	}
+	if err := r.InsertIf(3, ")", autoPair); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.InsertIf(7, ")", autoPair); err != nil {
+		t.Fatal(err)
+	}
+	if s := r.String(); s != "(a)) (b)" {
+		t.Fatalf("Incorrect result: got %q", s)
+	}
+
+	var runes []rune
+	var oks []bool
+	record := func(before, after rune, ok bool) bool {
+		runes = append(runes, before, after)
+		oks = append(oks, ok)
+		return false
+	}
+	r = CreateRope("xΩ")
+	for i := 0; i <= r.Length(); i++ {
+		r.InsertIf(i, "-", record)
+	}
+	if !reflect.DeepEqual(runes, []rune{-1, 'x', 'x', 'Ω', 'Ω', -1}) || !reflect.DeepEqual(oks, []bool{false, true, false}) {
+		t.Fatalf("Incorrect arguments: got %q, %v", runes, oks)
+	}
+	if s := r.String(); s != "xΩ" {
+		t.Fatalf("Skipped inserts changed the rope: got %q", s)
+	}
+
+	if err := r.InsertIf(3, "-", autoPair); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("Expected error for out of bounds position")
+	}
+}
+
+func Test_Partition(t *testing.T) {
+	loopTest(t, "Partition", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+
+		for _, n := range []int{1, 2, 3, 7, 16} {
+			parts := r.Partition(n)
+			if len(parts) == 0 || len(parts) > n {
+				t.Fatalf("Incorrect partition count: expected up to %d, got %d", n, len(parts))
+			}
+
+			var buf bytes.Buffer
+			for _, p := range parts {
+				s := p.String()
+				if s == "" || !utf8.ValidString(s) {
+					t.Fatalf("Invalid partition %q", s)
+				}
+				if size := p.ByteLength() - len(init)/n; size < -utf8.UTFMax || size > utf8.UTFMax {
+					t.Fatalf("Partition is not of even size: expected about %d, got %d", len(init)/n, p.ByteLength())
+				}
+				buf.WriteString(s)
+			}
+			if result := buf.String(); result != init {
+				t.Fatalf("Partitions do not equal original:\nExpected:\n'%+q'\nGet:\n'%+q'", init, result)
+			}
+
+			again := r.Partition(n)
+			for i := range parts {
+				if parts[i].Length() != again[i].Length() {
+					t.Fatal("Partition boundaries are not deterministic")
+				}
+			}
+		}
+	})
+
+	if parts := CreateRope("").Partition(4); len(parts) != 0 {
+		t.Fatalf("Empty rope produced %d partitions", len(parts))
+	}
+	if parts := CreateRope("🐿").Partition(4); len(parts) != 1 {
+		t.Fatalf("Single rune rope produced %d partitions", len(parts))
+	}
+}
+
+func Test_Prefix(t *testing.T) {
+	loopTest(t, "Prefix", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		r := CreateRope(init)
+
+		for _, n := range []int{0, 1, stringSize.size / 2, stringSize.size - 1, stringSize.size} {
+			p, err := r.Prefix(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := string(runes[:n])
+			if p.Length() != n {
+				t.Fatalf("Incorrect length: expected %d, got %d", n, p.Length())
+			}
+			if p.ByteLength() != len(expected) {
+				t.Fatalf("Incorrect byte length: expected %d, got %d", len(expected), p.ByteLength())
+			}
+			if result := p.String(); result != expected {
+				t.Fatalf("Prefix failed:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+			}
+		}
+
+		if _, err := r.Prefix(stringSize.size + 1); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatal("Expected error for prefix longer than rope")
+		}
+		if _, err := r.Prefix(-1); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatal("Expected error for negative prefix")
+		}
+	})
+}
+
+func Test_Suffix(t *testing.T) {
+	loopTest(t, "Suffix", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		r := CreateRope(init)
+
+		for _, n := range []int{0, 1, stringSize.size / 2, stringSize.size - 1, stringSize.size} {
+			s, err := r.Suffix(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := string(runes[stringSize.size-n:])
+			if s.Length() != n {
+				t.Fatalf("Incorrect length: expected %d, got %d", n, s.Length())
+			}
+			if s.ByteLength() != len(expected) {
+				t.Fatalf("Incorrect byte length: expected %d, got %d", len(expected), s.ByteLength())
+			}
+			if result := s.String(); result != expected {
+				t.Fatalf("Suffix failed:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+			}
+		}
+
+		if _, err := r.Suffix(stringSize.size + 1); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatal("Expected error for suffix longer than rope")
+		}
+	})
+}
+
+func Test_Gather(t *testing.T) {
+	loopTest(t, "Gather", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		size := stringSize.size
+
+		tests := []struct {
+			name     string
+			ranges   []Range
+			expected string
+		}{
+			{"none", nil, ""},
+			{"single", []Range{{10, 20}}, string(runes[10:20])},
+			{"unordered", []Range{{size / 2, size}, {0, 10}}, string(runes[0:10]) + string(runes[size/2:])},
+			{"overlapping", []Range{{10, 30}, {20, 40}, {35, 36}}, string(runes[10:40])},
+			{"adjacent", []Range{{10, 20}, {20, 30}, {50, 50}}, string(runes[10:30])},
+			{"all", []Range{{size / 3, size}, {0, size / 2}}, string(runes)},
+		}
+		for _, tc := range tests {
+			gathered, err := r.Gather(tc.ranges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gathered.String() != tc.expected {
+				t.Fatalf("Incorrect result for %s:\nExpected:\n%s\nGot:\n%s", tc.name, tc.expected, gathered.String())
+			}
+			if err := gathered.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		gathered, _ := r.Gather([]Range{{0, 10}, {20, 30}})
+		gathered.Insert(5, "x")
+		r.Remove(0, 25)
+		if gathered.String() != string(runes[0:5])+"x"+string(runes[5:10])+string(runes[20:30]) {
+			t.Fatal("Gathered rope is not independent")
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		rg       Range
+		expected error
+	}{{Range{-1, 1}, ErrIndexOutOfRange}, {Range{0, 4}, ErrIndexOutOfRange}, {Range{2, 1}, ErrInvalidRange}} {
+		if _, err := r.Gather([]Range{{0, 1}, tc.rg}); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for %v, got %v", tc.expected, tc.rg, err)
+		}
+	}
+}
+
+func Test_Concat(t *testing.T) {
+	loopTest(t, "Concat", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		leftInit := charSet.generator(stringSize.size)
+		rightInit := charSet.generator(stringSize.size*3) + "\n"
+		left := CreateRopeWithOptions(leftInit, Options{MaxBytes: 1 << 20})
+		right := CreateRope(rightInit)
+
+		result := Concat(left, right)
+		if result.String() != leftInit+rightInit {
+			t.Fatal("Incorrect content")
+		}
+		if result.Length() != left.Length()+right.Length() || result.ByteLength() != left.ByteLength()+right.ByteLength() || result.LineCount() != 2 {
+			t.Fatal("Incorrect counts")
+		}
+		if result.options != left.options {
+			t.Fatal("Options of left were not kept")
+		}
+		if err := result.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		assertLogarithmicDepth(t, result)
+
+		result.Insert(stringSize.size, "x")
+		left.Append("y")
+		right.Remove(0, 10)
+		if result.String() != leftInit+"x"+rightInit || left.String() != leftInit+"y" || right.String() != string([]rune(rightInit)[10:]) {
+			t.Fatal("Concatenated ropes are not independent")
+		}
+	})
+
+	r := CreateRope("abc")
+	empty := CreateRope("")
+	for _, tc := range []struct {
+		name        string
+		left, right *Rope
+		expected    string
+	}{
+		{"empty-left", empty, r, "abc"},
+		{"empty-right", r, empty, "abc"},
+		{"nil-left", nil, r, "abc"},
+		{"nil-right", r, nil, "abc"},
+		{"both-empty", empty, nil, ""},
+		{"both-nil", nil, nil, ""},
+	} {
+		result := Concat(tc.left, tc.right)
+		if result.String() != tc.expected {
+			t.Fatalf("Incorrect content for %s: %q", tc.name, result.String())
+		}
+		result.Append("!")
+		if r.String() != "abc" || empty.String() != "" {
+			t.Fatalf("Edit of result changed an operand for %s", tc.name)
+		}
+	}
+}
+
+func Test_Concat_Many(t *testing.T) {
+	var expected strings.Builder
+	result := CreateRope("")
+	for i := 0; i < 500; i++ {
+		part := generateASCIIString(1000 + i)
+		expected.WriteString(part)
+		result = Concat(result, CreateRope(part))
+	}
+	if result.String() != expected.String() {
+		t.Fatal("Incorrect content")
+	}
+	if err := result.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	assertLogarithmicDepth(t, result)
+}
+
+func Test_Prefix_Independent(t *testing.T) {
+	loopTest(t, "Prefix-Independent", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		r := CreateRope(init)
+
+		n := stringSize.size * 3 / 4
+		p, err := r.Prefix(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := r.Suffix(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Insert(1, "a")
+		r.Remove(n-2, n-1)
+		p.Insert(n/2, "b")
+		s.Alter(1, n/2, "c")
+
+		expected := string(runes[:1]) + "a" + string(runes[1:n-3]) + string(runes[n-2:])
+		if result := r.String(); result != expected {
+			t.Fatalf("Original was altered:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+		}
+		expected = string(runes[:n/2]) + "b" + string(runes[n/2:n])
+		if result := p.String(); result != expected {
+			t.Fatalf("Prefix was altered:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+		}
+		tail := runes[stringSize.size-n:]
+		expected = string(tail[:1]) + "c" + string(tail[n/2:])
+		if result := s.String(); result != expected {
+			t.Fatalf("Suffix was altered:\nExpected:\n'%+q'\nGet:\n'%+q'", expected, result)
+		}
+	})
+}
+
+func Test_Split(t *testing.T) {
+	loopTest(t, "Split", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		r := CreateRope(init)
+
+		for _, i := range []int{0, 1, stringSize.size / 2, stringSize.size} {
+			left, right, err := r.Split(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left.String() != string(runes[:i]) || right.String() != string(runes[i:]) {
+				t.Fatalf("Incorrect split at %d", i)
+			}
+			if err := left.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			if err := right.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+
+			left.Append("a")
+			right.Insert(0, "b")
+			if r.String() != init {
+				t.Fatalf("Original was altered by an edit of a split at %d", i)
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, i := range []int{-1, 4} {
+		if _, _, err := r.Split(i); err != ErrIndexOutOfRange {
+			t.Fatalf("Split at %d: expected ErrIndexOutOfRange, got %v", i, err)
+		}
+	}
+}
+
+func Test_Revision(t *testing.T) {
+	r := CreateRope("abc")
+	if r.Revision() != 0 {
+		t.Fatalf("Incorrect initial revision: %d", r.Revision())
+	}
+
+	r.Insert(1, "x")
+	r.Remove(0, 1)
+	r.Alter(0, 1, "y")
+	r.SetContent("def")
+	if r.Revision() != 4 {
+		t.Fatalf("Incorrect revision after edits: expected 4, got %d", r.Revision())
+	}
+
+	r.Insert(10, "x")
+	r.Prefix(1)
+	r.Rebalance()
+	if r.Revision() != 4 {
+		t.Fatalf("Revision advanced without an edit: %d", r.Revision())
+	}
+}
+
+func Test_CompareAndSwapRevision(t *testing.T) {
+	r := CreateRope("abc")
+
+	revision, err := r.CompareAndSwapRevision(0, func(r *Rope) error {
+		return r.Insert(3, "d")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision != 1 || r.String() != "abcd" {
+		t.Fatalf("Swap failed: revision %d, content %q", revision, r.String())
+	}
+
+	called := false
+	revision, err = r.CompareAndSwapRevision(0, func(r *Rope) error {
+		called = true
+		return nil
+	})
+	if err != ErrRevisionChanged {
+		t.Fatalf("Expected ErrRevisionChanged, got %v", err)
+	}
+	if called || revision != 1 {
+		t.Fatalf("Stale swap was applied: revision %d", revision)
+	}
+
+	expected := fmt.Errorf("failed")
+	if _, err = r.CompareAndSwapRevision(1, func(r *Rope) error { return expected }); err != expected {
+		t.Fatalf("Mutation error was not returned: %v", err)
+	}
+}
+
+func Test_CompareAndSwapRevision_Concurrent(t *testing.T) {
+	r := CreateRope("")
+	writers := 8
+	edits := 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			revision := uint64(0)
+			for j := 0; j < edits; {
+				next, err := r.CompareAndSwapRevision(revision, func(r *Rope) error {
+					return r.Insert(r.Length(), "a")
+				})
+				revision = next
+				if err == nil {
+					j++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if r.Length() != writers*edits || r.Revision() != uint64(writers*edits) {
+		t.Fatalf("Lost edits: length %d, revision %d", r.Length(), r.Revision())
+	}
+}
+
+func Test_SetContent(t *testing.T) {
+	loopTest(t, "SetContent", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		p, _ := r.Prefix(stringSize.size / 2)
+		prefix := p.String()
+
+		for _, size := range []int{0, stringSize.size / 2, stringSize.size * 3} {
+			s := charSet.generator(size)
+			r.SetContent(s)
+
+			if r.Length() != size {
+				t.Fatalf("Incorrect length: expected %d, got %d", size, r.Length())
+			}
+			if r.ByteLength() != len(s) {
+				t.Fatalf("Incorrect byte length: expected %d, got %d", len(s), r.ByteLength())
+			}
+			if result := r.String(); result != s {
+				t.Fatalf("SetContent failed:\nExpected:\n'%+q'\nGet:\n'%+q'", s, result)
+			}
+			assertLogarithmicDepth(t, r)
+		}
+
+		if result := p.String(); result != prefix {
+			t.Fatalf("Prefix was altered:\nExpected:\n'%+q'\nGet:\n'%+q'", prefix, result)
+		}
+	})
+}
+
+func Test_SetContent_Reuses_Nodes(t *testing.T) {
+	a := generateASCIIString(20000)
+	b := generateASCIIString(20000)
+	r := CreateRope(a)
+
+	reused := testing.AllocsPerRun(10, func() {
+		r.SetContent(b)
+		r.SetContent(a)
+	})
+	fresh := testing.AllocsPerRun(10, func() {
+		r = &Rope{root: build(nil, b, fillLength)}
+		r = &Rope{root: build(nil, a, fillLength)}
+	})
+
+	// Each leaf still allocates its string header, but the nodes themselves
+	// account for the majority of the allocations of a fresh tree.
+	if reused > fresh/2 {
+		t.Fatalf("SetContent allocated %f times, against %f for a fresh tree", reused, fresh)
+	}
+}
+
+func Test_Overwrite(t *testing.T) {
+	loopTest(t, "Overwrite", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		length := len(runes)
+		for _, tc := range []struct {
+			start int
+			s     string
+		}{
+			{0, "🐿x"},
+			{length / 2, charSet.generator(length / 4)},
+			{length - 3, "abcdef"},
+			{length, "end"},
+			{10, ""},
+		} {
+			r := CreateRope(string(runes))
+			if err := r.Overwrite(tc.start, tc.s); err != nil {
+				t.Fatal(err)
+			}
+			end := min(tc.start+utf8.RuneCountInString(tc.s), length)
+			expected := string(runes[:tc.start]) + tc.s + string(runes[end:])
+			if r.String() != expected {
+				t.Fatalf("Incorrect overwrite at %d", tc.start)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	if err := r.Overwrite(4, "x"); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+}
+
+func Test_Reset(t *testing.T) {
+	a := generateASCIIString(20000)
+	b := generateASCIIString(20000)
+	r := CreateRope(a)
+	m, _ := r.Mark(100)
+
+	r.Reset()
+	if !r.IsEmpty() || r.String() != "" {
+		t.Fatal("Reset did not empty the rope")
+	}
+	if _, valid := m.Position(); valid {
+		t.Fatal("Marker was not invalidated")
+	}
+	if len(r.pool) == 0 {
+		t.Fatal("Reset kept no nodes")
+	}
+	for _, n := range r.pool {
+		if n.value != nil || n.left != nil {
+			t.Fatal("Kept node was not cleared")
+		}
+	}
+
+	// The kept nodes serve the next SetContent, as the old tree does
+	reused := testing.AllocsPerRun(10, func() {
+		r.Reset()
+		r.SetContent(b)
+	})
+	fresh := testing.AllocsPerRun(10, func() {
+		r = &Rope{root: build(nil, b, fillLength)}
+	})
+	if reused > fresh/2 {
+		t.Fatalf("Reset and SetContent allocated %f times, against %f for a fresh tree", reused, fresh)
+	}
+	if r.String() != b {
+		t.Fatal("Incorrect content after SetContent")
+	}
+
+	// Nodes shared with another rope are not kept
+	r = CreateRope(a)
+	p := r.Snapshot()
+	r.Reset()
+	if len(r.pool) != 0 || p.String() != a {
+		t.Fatal("Reset took nodes shared with a snapshot")
+	}
+}
+
+func Test_Truncate(t *testing.T) {
+	loopTest(t, "Truncate", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size * 10)
+		runes := []rune(init)
+
+		for _, n := range []int{0, 1, stringSize.size, len(runes) / 2, len(runes) - 1, len(runes)} {
+			r := CreateRope(init)
+			snapshot := r.Snapshot()
+			r.Insert(len(runes)/3, "Ω")
+			expected := slices.Insert(slices.Clone(runes), len(runes)/3, 'Ω')
+			shared := r.Snapshot()
+
+			if err := r.Truncate(n); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != string(expected[:n]) {
+				t.Fatalf("Incorrect truncate to %d", n)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+
+			r.Append("x")
+			if snapshot.String() != init || shared.String() != string(expected) {
+				t.Fatal("Truncate changed a snapshot")
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, n := range []int{-1, 4} {
+		if err := r.Truncate(n); err != ErrIndexOutOfRange {
+			t.Fatalf("Truncate to %d: expected ErrIndexOutOfRange, got %v", n, err)
+		}
+	}
+}
+
+func Test_Shape(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     int
+		edit     func(r *Rope)
+		expected string
+	}{
+		{"empty", 0, func(r *Rope) {}, "0"},
+		{"leaf", 100, func(r *Rope) {}, "100"},
+		{"split", 600, func(r *Rope) {}, "(300 300)"},
+		{"split-twice", 1200, func(r *Rope) {}, "((300 300) (300 300))"},
+		{"insert", 600, func(r *Rope) { r.Insert(0, generateASCIIString(10)) }, "(310 300)"},
+		{"insert-split", 600, func(r *Rope) { r.Insert(0, generateASCIIString(300)) }, "((300 300) 300)"},
+		{"remove-join", 600, func(r *Rope) { r.Remove(0, 400) }, "200"},
+		{"rebalance", 600, func(r *Rope) { r.Insert(0, generateASCIIString(300)); r.Rebalance() }, "(450 450)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRope(generateASCIIString(tc.init))
+			tc.edit(r)
+			if shape := r.Shape(); shape != tc.expected {
+				t.Fatalf("Incorrect shape: expected %s, got %s", tc.expected, shape)
+			}
+		})
+	}
+}
+
+func Test_AutoBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"enabled", Options{}},
+		{"disabled", Options{DisableAutoBalance: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := CreateRopeWithOptions("", tc.options)
+			var buf bytes.Buffer
+			for i := 0; i < 2000; i++ {
+				s := generateASCIIString(10)
+				buf.WriteString(s)
+				r.Insert(r.Length(), s)
+			}
+
+			if result := r.String(); result != buf.String() {
+				t.Fatalf("Append failed:\nExpected:\n'%+q'\nGet:\n'%+q'", buf.String(), result)
+			}
+
+			if tc.options.DisableAutoBalance {
+				if r.root.depth <= maxDepth(r.Length()) {
+					t.Fatalf("Tree was balanced: depth %d", r.root.depth)
+				}
+				r.Rebalance()
+				if result := r.String(); result != buf.String() {
+					t.Fatal("Rebalance altered the content")
+				}
+			}
+			assertLogarithmicDepth(t, r)
+		})
+	}
+}
+
+func Test_AutoBalance_Single_Runes(t *testing.T) {
+	positions := map[string]func(r *Rope) int{
+		"end":    func(r *Rope) int { return r.Length() },
+		"start":  func(r *Rope) int { return 0 },
+		"middle": func(r *Rope) int { return r.Length() / 2 },
+	}
+
+	for name, position := range positions {
+		t.Run(name, func(t *testing.T) {
+			r := CreateRope("")
+			for i := 0; i < 10000; i++ {
+				if err := r.Insert(position(r), string(rune('a'+i%26))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A balanced tree of 10000 runes in leaves of at least joinLength
+			// runes is 6 deep, so allow a small multiple of log2 of the length
+			if limit := 2 * int(math.Ceil(math.Log2(float64(r.Length())))); r.root.depth > limit {
+				t.Fatalf("Tree is %d deep, more than %d", r.root.depth, limit)
+			}
+			assertLogarithmicDepth(t, r)
+
+			content, length, byteLength := r.String(), r.Length(), r.ByteLength()
+			r.Rebalance()
+			if r.String() != content || r.Length() != length || r.ByteLength() != byteLength {
+				t.Fatal("Rebalance altered the content")
+			}
+		})
+	}
+}
+
+func Test_AppendRuneN(t *testing.T) {
+	for _, ru := range []rune{' ', '\n', 'Ω', '🐈'} {
+		for _, count := range []int{0, 1, 100, 512, 10000} {
+			t.Run(fmt.Sprintf("%q-%d", ru, count), func(t *testing.T) {
+				init := generateUnicodeString(300)
+				r := CreateRope(init)
+				if err := r.AppendRuneN(ru, count); err != nil {
+					t.Fatal(err)
+				}
+
+				expected := init + strings.Repeat(string(ru), count)
+				if r.String() != expected {
+					t.Fatal("Incorrect content")
+				}
+				if r.Length() != 300+count || r.ByteLength() != len(expected) || r.LineCount() != strings.Count(expected, "\n")+1 {
+					t.Fatalf("Incorrect counts: %d runes, %d bytes, %d lines", r.Length(), r.ByteLength(), r.LineCount())
+				}
+				if err := r.CheckInvariants(); err != nil {
+					t.Fatal(err)
+				}
+				assertLogarithmicDepth(t, r)
+
+				runes := []rune(expected)
+				r.Insert(len(runes)/2, "x")
+				if r.String() != string(runes[:len(runes)/2])+"x"+string(runes[len(runes)/2:]) {
+					t.Fatal("Incorrect content after insert")
+				}
+			})
+		}
+	}
+
+	r := CreateRopeWithOptions("abc", Options{MaxBytes: 10})
+	if err := r.AppendRuneN('🐈', 2); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if err := r.AppendRuneN('x', -1); err == nil {
+		t.Fatal("Expected error for negative count")
+	}
+	if r.String() != "abc" {
+		t.Fatalf("Failed append changed the rope: %q", r.String())
+	}
+}
+
+func Test_BalanceWithFill(t *testing.T) {
+	for _, ratio := range []float64{0.1, 0.5, 0.8, 1, 2} {
+		t.Run(fmt.Sprintf("%g", ratio), func(t *testing.T) {
+			init := []rune(generateUnicodeString(20000))
+			r := CreateRope("")
+			for i := 0; i < len(init); i += 100 {
+				r.Append(string(init[i:min(i+100, len(init))]))
+			}
+			expected := r.String()
+
+			r.BalanceWithFill(ratio)
+			if r.String() != expected {
+				t.Fatal("Content changed")
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			assertLogarithmicDepth(t, r)
+
+			target := int(math.Min(math.Max(ratio, 0.25), 1) * splitLength)
+			leaves := r.root.leaves(nil, false)
+			for i, leaf := range leaves {
+				if leaf.length > target || leaf.length < target*9/10 {
+					t.Fatalf("Leaf %d of %d has %d runes; expected about %d", i, len(leaves), leaf.length, target)
+				}
+			}
+		})
+	}
+}
+
+func Test_Append(t *testing.T) {
+	loopTest(t, "Append", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		var buf bytes.Buffer
+		buf.WriteString(init)
+		for i := 0; i < 3000; i++ {
+			s := charSet.generator(i % 13)
+			if i%7 == 0 {
+				s += "\n"
+			}
+			buf.WriteString(s)
+
+			switch i % 1000 {
+			case 250:
+				// Sharing the tree must stop appends growing the last leaf in
+				// place.
+				p, _ := r.Prefix(r.Length())
+				r.Append(s)
+				if p.Length() != r.Length()-utf8.RuneCountInString(s) {
+					t.Fatal("Append altered a shared prefix")
+				}
+			case 500:
+				position := r.Length() / 2
+				r.Insert(position, "Ω")
+				r.Remove(position, position+1)
+				r.Append(s)
+			case 750:
+				fmt.Fprint(r, s)
+			default:
+				r.Append(s)
+			}
+		}
+
+		expected := CreateRope(buf.String())
+		if r.String() != expected.String() {
+			t.Fatalf("Append failed")
+		}
+		if r.Length() != expected.Length() || r.ByteLength() != expected.ByteLength() || r.LineCount() != expected.LineCount() {
+			t.Fatalf("Incorrect counts: expected %d/%d/%d, got %d/%d/%d", expected.Length(), expected.ByteLength(), expected.LineCount(), r.Length(), r.ByteLength(), r.LineCount())
+		}
+		assertLogarithmicDepth(t, r)
+	})
+}
+
+func Test_Prepend(t *testing.T) {
+	loopTest(t, "Prepend", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		m, _ := r.Mark(1)
+		expected := init
+		prepended := 0
+		for i := 0; i < 3000; i++ {
+			s := charSet.generator(i % 13)
+			if i%7 == 0 {
+				s += "\n"
+			}
+			expected = s + expected
+			prepended += utf8.RuneCountInString(s)
+
+			switch i % 1000 {
+			case 250:
+				p, _ := r.Prefix(r.Length())
+				r.Prepend(s)
+				if p.Length() != r.Length()-utf8.RuneCountInString(s) {
+					t.Fatal("Prepend altered a shared prefix")
+				}
+			case 500:
+				r.Prepend(s)
+				r.Append("Ω")
+				expected += "Ω"
+			default:
+				r.Prepend(s)
+			}
+		}
+
+		if r.String() != expected {
+			t.Fatalf("Prepend failed")
+		}
+		if position, _ := m.Position(); position != prepended+1 {
+			t.Fatalf("Marker was not moved: expected %d, got %d", prepended+1, position)
+		}
+		if err := r.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		assertLogarithmicDepth(t, r)
+	})
+}
+
+func Test_AutoBalance_Options_Inherited(t *testing.T) {
+	r := CreateRopeWithOptions(generateASCIIString(1000), Options{DisableAutoBalance: true})
+	p, _ := r.Prefix(500)
+	if !p.options.DisableAutoBalance {
+		t.Fatal("Prefix did not inherit options")
+	}
+}
+
+func Test_Freeze(t *testing.T) {
+	init := generateUnicodeString(2000) + "\nfoo\n"
+	r := CreateRope(init)
+	r.Append("bar")
+	r.Freeze()
+	if !r.IsFrozen() {
+		t.Fatal("Expected rope to be frozen")
+	}
+
+	expected := init + "bar"
+	mutations := []struct {
+		name   string
+		mutate func() error
+	}{
+		{"Alter", func() error { return r.Alter(0, 1, "x") }},
+		{"Append", func() error { return r.Append("x") }},
+		{"AppendRuneN", func() error { return r.AppendRuneN('x', 3) }},
+		{"ApplyEdits", func() error { return r.ApplyEdits([]EditOp{{0, 1, "x"}}) }},
+		{"ApplyLSPEdits", func() error { return r.ApplyLSPEdits([]LSPEdit{{NewText: "x"}}) }},
+		{"ApplyUnifiedDiff", func() error { return r.ApplyUnifiedDiff("@@ -1,0 +1 @@\n+x\n") }},
+		{"Cut", func() error { _, err := r.Cut(0, 5); return err }},
+		{"Duplicate", func() error { return r.Duplicate(0, 5, 10) }},
+		{"Insert", func() error { return r.Insert(0, "x") }},
+		{"InsertIf", func() error {
+			return r.InsertIf(0, "x", func(before, after rune, ok bool) bool { return true })
+		}},
+		{"InsertLineAfter", func() error { return r.InsertLineAfter(0, "x", "") }},
+		{"InsertRope", func() error { return r.InsertRope(0, CreateRope("x")) }},
+		{"Move", func() error { return r.Move(0, 5, 10) }},
+		{"Overwrite", func() error { return r.Overwrite(0, "x") }},
+		{"Prepend", func() error { return r.Prepend("x") }},
+		{"Recompute", r.Recompute},
+		{"Remove", func() error { return r.Remove(0, 5) }},
+		{"ReplaceFunc", func() error {
+			_, err := r.ReplaceFunc(regexp.MustCompile("foo"), strings.ToUpper)
+			return err
+		}},
+		{"ReplaceRangeReturning", func() error { _, err := r.ReplaceRangeReturning(0, 5, "x"); return err }},
+		{"Truncate", func() error { return r.Truncate(1) }},
+		{"Write", func() error { _, err := r.Write([]byte("x")); return err }},
+	}
+	for _, m := range mutations {
+		if err := m.mutate(); err != ErrFrozen {
+			t.Fatalf("%s: expected ErrFrozen, got %v", m.name, err)
+		}
+	}
+
+	r.Rebalance()
+	r.BalanceWithFill(0.5)
+	for name, reset := range map[string]func(){"SetContent": func() { r.SetContent("x") }, "Reset": r.Reset} {
+		func() {
+			defer func() {
+				if recover() != ErrFrozen {
+					t.Fatalf("Expected %s to panic with ErrFrozen", name)
+				}
+			}()
+			reset()
+		}()
+	}
+
+	if r.String() != expected || r.Revision() != 1 {
+		t.Fatal("Frozen rope was changed")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prefix, _ := r.Prefix(100)
+			v := FromVersion(r.Root())
+			if r.String() != expected || r.ContentID() == "" || prefix.String() != string([]rune(expected)[:100]) || v.String() != expected {
+				t.Error("Incorrect read of frozen rope")
+			}
+		}()
+	}
+	wg.Wait()
+
+	copied, _ := r.Prefix(r.Length())
+	if err := copied.Insert(0, "x"); err != nil || copied.String() != "x"+expected || r.String() != expected {
+		t.Fatal("Prefix of a frozen rope is not editable")
+	}
+}
+
+func Test_MaxBytes(t *testing.T) {
+	r := CreateRopeWithOptions("ΩΩΩ", Options{MaxBytes: 10})
+	edits := []struct {
+		name string
+		edit func() error
+	}{
+		{"Insert", func() error { return r.Insert(1, "abcde") }},
+		{"Alter", func() error { return r.Alter(0, 1, "abcdefg") }},
+		{"Append", func() error { return r.Append("abcde") }},
+		{"Write", func() error { _, err := r.Write([]byte("abcde")); return err }},
+		{"ReplaceRangeReturning", func() error { _, err := r.ReplaceRangeReturning(2, 3, "abcdefg"); return err }},
+		{"ApplyLSPEdits", func() error { return r.ApplyLSPEdits([]LSPEdit{{LSPPosition{0, 0}, LSPPosition{0, 0}, "abcde"}}) }},
+	}
+
+	for _, tc := range edits {
+		if err := tc.edit(); err != ErrTooLarge {
+			t.Fatalf("%s straddling the limit: expected ErrTooLarge, got %v", tc.name, err)
+		}
+		if r.String() != "ΩΩΩ" || r.Revision() != 0 {
+			t.Fatalf("%s beyond the limit changed the rope", tc.name)
+		}
+	}
+
+	if err := r.Insert(3, "abcd"); err != nil {
+		t.Fatalf("Insert up to the limit failed: %v", err)
+	}
+	if err := r.Alter(0, 1, "xy"); err != nil {
+		t.Fatalf("Alter which does not grow the rope failed: %v", err)
+	}
+	if err := r.Append("z"); err != ErrTooLarge {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if r.ByteLength() != 10 {
+		t.Fatalf("Incorrect byte length: expected 10, got %d", r.ByteLength())
+	}
+
+	// Edits which shrink a rope over the limit are allowed.
+	r = CreateRopeWithOptions(generateASCIIString(100), Options{MaxBytes: 10})
+	if err := r.Alter(0, 50, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ApplyLSPEdits([]LSPEdit{{LSPPosition{0, 0}, LSPPosition{0, 0}, "abc"}, {LSPPosition{0, 1}, LSPPosition{0, 10}, ""}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Reader(t *testing.T) {
+	loopTest(t, "Reader", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+
+		var buf bytes.Buffer
+		buf.Grow(len(init))
+
+		r := CreateRope(init)
+		reader := r.NewReader()
+
+		io.Copy(&buf, reader)
+
+		result := string(buf.Bytes())
+		if strings.Compare(result, init) != 0 {
 			t.Fatalf("Read failed:\nExpected:\n'%s'\nGot:\n'%s'", init, result)
 		}
 	})
 }
 
+func Test_Reader_Small_Reads(t *testing.T) {
+	loopTest(t, "Reader-Small-Reads", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		reader := CreateRope(init).NewReader()
+
+		var buf bytes.Buffer
+		p := make([]byte, 7)
+		for {
+			n, err := reader.Read(p)
+			buf.Write(p[:n])
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if result := buf.String(); result != init {
+			t.Fatalf("Read failed:\nExpected:\n'%+q'\nGot:\n'%+q'", init, result)
+		}
+	})
+}
+
+func Test_Reader_ReadRune(t *testing.T) {
+	loopTest(t, "Reader-ReadRune", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		reader := r.NewReader().(io.RuneReader)
+
+		var runes []rune
+		for {
+			ru, size, err := reader.ReadRune()
+			if err == io.EOF {
+				break
+			}
+			if size != utf8.RuneLen(ru) {
+				t.Fatalf("Incorrect size for %q: got %d", ru, size)
+			}
+			runes = append(runes, ru)
+		}
+		if string(runes) != init {
+			t.Fatalf("ReadRune failed")
+		}
+
+		// An edit replaces the leaf being read.
+		read := &Reader{r: r}
+		read.ReadRune()
+		r.Alter(1, 2, "Ω")
+		if ru, _, _ := read.ReadRune(); ru != 'Ω' {
+			t.Fatalf("ReadRune read a stale leaf: got %q", ru)
+		}
+	})
+}
+
+func Test_Pipe(t *testing.T) {
+	// upper converts ASCII letters byte by byte, so that runes divided
+	// between reads are left intact
+	upper := func(read io.Reader) io.Reader {
+		pr, pw := io.Pipe()
+		go func() {
+			b := make([]byte, 64)
+			for {
+				n, err := read.Read(b)
+				for i, c := range b[:n] {
+					if 'a' <= c && c <= 'z' {
+						b[i] = c - 'a' + 'A'
+					}
+				}
+				pw.Write(b[:n])
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}()
+		return pr
+	}
+	double := func(read io.Reader) io.Reader {
+		var buf bytes.Buffer
+		return io.MultiReader(io.TeeReader(read, &buf), &buf)
+	}
+
+	loopTest(t, "Pipe", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+
+		b, err := io.ReadAll(r.Pipe(upper, double))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := strings.Repeat(strings.Map(func(ru rune) rune {
+			if 'a' <= ru && ru <= 'z' {
+				return ru - 'a' + 'A'
+			}
+			return ru
+		}, init), 2)
+		if string(b) != expected {
+			t.Fatalf("Incorrect result:\nExpected:\n%s\nGot:\n%s", expected, string(b))
+		}
+
+		if b, _ := io.ReadAll(r.Pipe()); string(b) != init {
+			t.Fatal("Incorrect result with no transforms")
+		}
+	})
+}
+
+func Test_ReadCloser(t *testing.T) {
+	loopTest(t, "ReadCloser", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		reader := CreateRope(init).NewReadCloser()
+		defer reader.Close()
+
+		result, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != init {
+			t.Fatalf("Read failed:\nExpected:\n'%+q'\nGot:\n'%+q'", init, result)
+		}
+		if err := reader.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_OpenReader_Seek(t *testing.T) {
+	loopTest(t, "OpenReader-Seek", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		reader := r.OpenReader()
+
+		if r.Size() != int64(len(init)) {
+			t.Fatalf("Incorrect size: expected %d, got %d", len(init), r.Size())
+		}
+
+		tests := []struct {
+			offset   int64
+			whence   int
+			expected int64
+		}{
+			{10, io.SeekStart, 10},
+			{5, io.SeekCurrent, 15},
+			{-20, io.SeekEnd, int64(len(init)) - 20},
+			{int64(len(init)) / 2, io.SeekStart, int64(len(init)) / 2},
+		}
+		for _, tc := range tests {
+			pos, err := reader.Seek(tc.offset, tc.whence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pos != tc.expected {
+				t.Fatalf("Incorrect position: expected %d, got %d", tc.expected, pos)
+			}
+		}
+
+		var buf bytes.Buffer
+		io.Copy(&buf, reader)
+		if result := buf.String(); result != init[len(init)/2:] {
+			t.Fatalf("Read after seek failed:\nExpected:\n'%+q'\nGot:\n'%+q'", init[len(init)/2:], result)
+		}
+
+		reader.Seek(3, io.SeekStart)
+		p := make([]byte, 10)
+		n, _ := io.ReadFull(reader, p)
+		if string(p[:n]) != init[3:13] {
+			t.Fatalf("Read after seek failed: expected %q, got %q", init[3:13], p[:n])
+		}
+
+		if _, err := reader.Seek(-1, io.SeekStart); err == nil {
+			t.Fatal("Expected error seeking before start")
+		}
+		if n, err := reader.Seek(10, io.SeekEnd); err != nil || n != int64(len(init))+10 {
+			t.Fatal("Failed to seek past end")
+		}
+		if _, err := reader.Read(p); err != io.EOF {
+			t.Fatal("Expected EOF reading past end")
+		}
+	})
+}
+
+func Test_OpenReader_ServeContent(t *testing.T) {
+	init := generateUnicodeString(100000)
+	r := CreateRope(init)
+
+	req := httptest.NewRequest(http.MethodGet, "/doc.txt", nil)
+	req.Header.Set("Range", "bytes=12345-54320")
+	w := httptest.NewRecorder()
+	http.ServeContent(w, req, "doc.txt", time.Time{}, r.OpenReader())
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Incorrect status: expected %d, got %d", http.StatusPartialContent, w.Code)
+	}
+	if result := w.Body.String(); result != init[12345:54321] {
+		t.Fatalf("Incorrect range returned: expected %d bytes, got %d", 54321-12345, len(result))
+	}
+}
+
 func Test_Remove_Small_From_Beginning(t *testing.T) {
 	loopTest(t, "Remove-From-Beginning", func(t *testing.T, charSet charSet, stringSize stringSize) {
 		init := charSet.generator(stringSize.size)
@@ -316,6 +2236,43 @@ func Test_Remove_Small_From_Beginning(t *testing.T) {
 	})
 }
 
+func Test_Insert_Remove_OutOfRange(t *testing.T) {
+	loopTest(t, "OutOfRange", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+
+		for _, position := range []int{-1, stringSize.size + 1, 5000} {
+			if err := r.Insert(position, "x"); err != ErrIndexOutOfRange {
+				t.Fatalf("Insert at %d: expected ErrIndexOutOfRange, got %v", position, err)
+			}
+		}
+
+		for _, tc := range []struct {
+			start, end int
+			expected   error
+		}{
+			{-1, 3, ErrIndexOutOfRange},
+			{0, stringSize.size + 1, ErrIndexOutOfRange},
+			{stringSize.size + 5, stringSize.size + 10, ErrIndexOutOfRange},
+			{10, 3, ErrInvalidRange},
+		} {
+			if err := r.Remove(tc.start, tc.end); err != tc.expected {
+				t.Fatalf("Remove %d-%d: expected %v, got %v", tc.start, tc.end, tc.expected, err)
+			}
+		}
+
+		if r.String() != init || r.Revision() != 0 {
+			t.Fatal("Failed edit changed the rope")
+		}
+		if err := r.Insert(stringSize.size, "x"); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Remove(0, stringSize.size+1); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
 func Test_Remove_Small_From_Middle(t *testing.T) {
 	loopTest(t, "Remove-From-Middle", func(t *testing.T, charSet charSet, stringSize stringSize) {
 		i := stringSize.size / 2
@@ -338,16 +2295,8 @@ func Test_Remove_Small_From_Middle(t *testing.T) {
 		}
 
 		result := r.String()
-		if !utf8.ValidString(result) {
-			b := []byte(result)
-			for i := 0; i < len(result); {
-				ru, n := utf8.DecodeRune(b)
-				if ru == utf8.RuneError {
-					t.Fatalf("Invalid UTF8 string; first instance at %d\n%s", i, result)
-				}
-				i += n
-			}
-			t.Fatal("Invalid UTF8 string")
+		if i := r.FirstInvalidUTF8(); i != -1 {
+			t.Fatalf("Invalid UTF8 string; first instance at %d\n%s", i, result)
 		}
 		expected := x1 + x3
 		if result != expected {
@@ -356,6 +2305,385 @@ func Test_Remove_Small_From_Middle(t *testing.T) {
 	})
 }
 
+func Test_ReplaceRangeReturning(t *testing.T) {
+	loopTest(t, "ReplaceRangeReturning", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		for _, span := range [][2]int{{0, 1}, {len(runes) / 3, 2 * len(runes) / 3}, {len(runes) - 1, len(runes)}, {0, len(runes)}, {len(runes) / 2, len(runes) / 2}} {
+			r := CreateRope(init)
+			removed, err := r.ReplaceRangeReturning(span[0], span[1], "🐿x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := string(runes[span[0]:span[1]]); removed != expected {
+				t.Fatalf("Incorrect removed text for %v: expected %q, got %q", span, expected, removed)
+			}
+			if expected := string(runes[:span[0]]) + "🐿x" + string(runes[span[1]:]); r.String() != expected {
+				t.Fatalf("Incorrect result for %v", span)
+			}
+		}
+	})
+}
+
+func Test_Cut(t *testing.T) {
+	loopTest(t, "Cut", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		runes := []rune(init)
+		for _, span := range [][2]int{{0, 1}, {len(runes) / 3, 2 * len(runes) / 3}, {len(runes) - 1, len(runes)}, {0, len(runes)}, {len(runes) / 2, len(runes) / 2}} {
+			r := CreateRope(init)
+			removed, err := r.Cut(span[0], span[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := string(runes[span[0]:span[1]]); removed != expected {
+				t.Fatalf("Incorrect removed text for %v: expected %q, got %q", span, expected, removed)
+			}
+			if expected := string(runes[:span[0]]) + string(runes[span[1]:]); r.String() != expected {
+				t.Fatalf("Incorrect result for %v", span)
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	if _, err := r.Cut(0, 4); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if _, err := r.Cut(2, 1); err != ErrInvalidRange {
+		t.Fatalf("Expected ErrInvalidRange, got %v", err)
+	}
+	if r.String() != "abc" || r.Revision() != 0 {
+		t.Fatal("Failed Cut changed the rope")
+	}
+}
+
+func Test_ReplaceRangeDelta(t *testing.T) {
+	loopTest(t, "ReplaceRangeDelta", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		for _, tc := range []struct {
+			start, end int
+			s          string
+		}{
+			{0, 0, "abc"},
+			{10, 20, "🐈"},
+			{stringSize.size / 2, stringSize.size, ""},
+			{0, stringSize.size, charSet.generator(stringSize.size * 2)},
+		} {
+			r := CreateRope(init)
+			runes, bytes := r.Length(), r.ByteLength()
+			revision := r.Revision()
+			runeDelta, byteDelta, err := r.ReplaceRangeDelta(tc.start, tc.end, tc.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Revision() != revision {
+				t.Fatal("Rope was changed")
+			}
+
+			r.Alter(tc.start, tc.end, tc.s)
+			if runeDelta != r.Length()-runes || byteDelta != r.ByteLength()-bytes {
+				t.Fatalf("Incorrect deltas for %d-%d: expected %d, %d, got %d, %d", tc.start, tc.end, r.Length()-runes, r.ByteLength()-bytes, runeDelta, byteDelta)
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		start, end int
+		expected   error
+	}{{-1, 1, ErrIndexOutOfRange}, {0, 4, ErrIndexOutOfRange}, {2, 1, ErrInvalidRange}} {
+		if _, _, err := r.ReplaceRangeDelta(tc.start, tc.end, "x"); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+}
+
+func Test_ReplaceRangeReturning_Invalid(t *testing.T) {
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		start, end int
+		expected   error
+	}{{-1, 1, ErrIndexOutOfRange}, {0, 4, ErrIndexOutOfRange}, {2, 1, ErrInvalidRange}} {
+		if _, err := r.ReplaceRangeReturning(tc.start, tc.end, "x"); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+	if r.String() != "abc" || r.Revision() != 0 {
+		t.Fatalf("Invalid range changed the rope")
+	}
+}
+
+func Test_Substring(t *testing.T) {
+	loopTest(t, "Substring", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		r.Insert(stringSize.size/2, "🐿🐿🐿")
+		runes = slices.Insert(runes, stringSize.size/2, []rune("🐿🐿🐿")...)
+		length := len(runes)
+
+		for _, span := range [][2]int{{0, 0}, {10, 10}, {0, length}, {5, 50}, {length / 2, length}, {length / 2, length/2 + 1}, {length - 1, length}} {
+			expected := string(runes[span[0]:span[1]])
+			s, err := r.Substring(span[0], span[1])
+			if err != nil || s != expected {
+				t.Fatalf("Incorrect substring for %v: expected %q, got %q, %v", span, expected, s, err)
+			}
+
+			sub, err := r.SubRope(span[0], span[1])
+			if err != nil || sub.String() != expected || sub.Length() != span[1]-span[0] {
+				t.Fatalf("Incorrect subrope for %v: %v", span, err)
+			}
+		}
+
+		if s, _ := r.Substring(stringSize.size/2+1, stringSize.size/2+2); s != "🐿" {
+			t.Fatalf("Expected a single rune, got %q", s)
+		}
+	})
+
+	r := CreateRope("abc")
+	for _, tc := range []struct {
+		start, end int
+		expected   error
+	}{{-1, 1, ErrIndexOutOfRange}, {0, 4, ErrIndexOutOfRange}, {2, 1, ErrInvalidRange}} {
+		if _, err := r.Substring(tc.start, tc.end); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+		if _, err := r.SubRope(tc.start, tc.end); !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v for [%d, %d), got %v", tc.expected, tc.start, tc.end, err)
+		}
+	}
+
+	sub, _ := r.SubRope(1, 3)
+	sub.Insert(0, "x")
+	if r.String() != "abc" || sub.String() != "xbc" {
+		t.Fatal("SubRope is not independent")
+	}
+}
+
+func Test_Substring_Allocations(t *testing.T) {
+	r := CreateRope(generateASCIIString(1 << 20))
+	start := 1 << 19
+
+	// A range within a single leaf is a slice of it, and a range across
+	// leaves is copied once, however large the rope
+	if allocs := testing.AllocsPerRun(100, func() { r.Substring(start, start+1) }); allocs != 0 {
+		t.Fatalf("Substring within a leaf made %g allocations", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { r.Substring(start, start+10000) }); allocs > 1 {
+		t.Fatalf("Substring across leaves made %g allocations", allocs)
+	}
+}
+
+func Test_SubRope_Allocations(t *testing.T) {
+	r := CreateRope(generateASCIIString(1 << 20))
+
+	// Only the nodes along the paths to the ends of the range are made; the
+	// subtrees between them are shared
+	allocs := testing.AllocsPerRun(100, func() { r.SubRope(1000, 1<<20-1000) })
+	if limit := float64(3*r.root.depth + 8); allocs > limit {
+		t.Fatalf("SubRope made %g allocations; expected at most %g for depth %d", allocs, limit, r.root.depth)
+	}
+}
+
+func Test_RuneAt(t *testing.T) {
+	r := CreateRope("🐿a🐿")
+	for i, expected := range []rune{'🐿', 'a', '🐿'} {
+		if ru, err := r.RuneAt(i); err != nil || ru != expected {
+			t.Fatalf("Incorrect rune at %d: expected %q, got %q, %v", i, expected, ru, err)
+		}
+	}
+	for _, position := range []int{-1, 3} {
+		if _, err := r.RuneAt(position); err != ErrIndexOutOfRange {
+			t.Fatalf("Expected ErrIndexOutOfRange at %d, got %v", position, err)
+		}
+	}
+
+	loopTest(t, "RuneAt", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		for i, expected := range runes {
+			if ru, err := r.RuneAt(i); err != nil || ru != expected {
+				t.Fatalf("Incorrect rune at %d: expected %q, got %q, %v", i, expected, ru, err)
+			}
+		}
+	})
+}
+
+func Test_RuneAt_Allocations(t *testing.T) {
+	r := CreateRope(generateUnicodeString(1 << 20))
+	if allocs := testing.AllocsPerRun(100, func() { r.RuneAt(1 << 19) }); allocs != 0 {
+		t.Fatalf("RuneAt made %g allocations", allocs)
+	}
+}
+
+func Test_Clamped(t *testing.T) {
+	loopTest(t, "Clamped", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+		last := len(runes) - 1
+
+		for _, tc := range []struct{ position, expected int }{{-5, 0}, {0, 0}, {last / 2, last / 2}, {last, last}, {last + 1, last}, {last + 100, last}} {
+			if ru := r.RuneAtClamped(tc.position); ru != runes[tc.expected] {
+				t.Fatalf("Incorrect rune at %d: expected %q, got %q", tc.position, runes[tc.expected], ru)
+			}
+		}
+
+		for _, tc := range []struct{ start, end, expectedStart, expectedEnd int }{
+			{-5, 10, 0, 10},
+			{10, last + 100, 10, last + 1},
+			{-5, last + 100, 0, last + 1},
+			{300, 299, 0, 0},
+			{last + 5, last + 10, 0, 0},
+		} {
+			expected := string(runes[tc.expectedStart:tc.expectedEnd])
+			if s := r.SubstringClamped(tc.start, tc.end); s != expected {
+				t.Fatalf("Incorrect substring for %d-%d", tc.start, tc.end)
+			}
+		}
+	})
+
+	r := CreateRope("")
+	if ru := r.RuneAtClamped(0); ru != utf8.RuneError {
+		t.Fatalf("Expected RuneError for empty rope, got %q", ru)
+	}
+	if s := r.SubstringClamped(-1, 1); s != "" {
+		t.Fatalf("Expected empty substring, got %q", s)
+	}
+}
+
+func Test_WriteEscaped(t *testing.T) {
+	escaper := func(ru rune) string {
+		switch ru {
+		case '<':
+			return "&lt;"
+		case '>':
+			return "&gt;"
+		case '&':
+			return "&amp;"
+		case '🐈':
+			return "&#x1F408;"
+		}
+		return ""
+	}
+	replacer := strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "🐈", "&#x1F408;")
+
+	loopTest(t, "WriteEscaped", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := "<p>" + charSet.generator(stringSize.size) + " & 🐈🍩 </p>" + charSet.generator(stringSize.size) + "<"
+		r := CreateRope(init)
+		r.Insert(stringSize.size/2, "<🐈>")
+		expected := replacer.Replace(r.String())
+
+		var buf bytes.Buffer
+		if err := r.WriteEscaped(&buf, escaper); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Fatalf("Incorrect result:\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+		}
+	})
+
+	var buf bytes.Buffer
+	if err := CreateRope("").WriteEscaped(&buf, escaper); err != nil || buf.Len() != 0 {
+		t.Fatalf("Incorrect result for empty rope: %q, %v", buf.String(), err)
+	}
+}
+
+func Test_WriteTo(t *testing.T) {
+	loopTest(t, "WriteTo", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		r.Insert(stringSize.size/2, charSet.generator(stringSize.size))
+
+		var buf bytes.Buffer
+		n, err := r.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(r.ByteLength()) || buf.String() != r.String() {
+			t.Fatalf("Incorrect result: wrote %d of %d bytes", n, r.ByteLength())
+		}
+	})
+
+	r := CreateRope(generateASCIIString(2000))
+	w := &limitedWriter{limit: 700}
+	n, err := r.WriteTo(w)
+	if err == nil || n != 700 || w.buf.String() != r.String()[:700] {
+		t.Fatalf("Expected short write of 700 bytes, got %d, %v", n, err)
+	}
+}
+
+// limitedWriter accepts up to limit bytes, and then fails
+type limitedWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if room := w.limit - w.buf.Len(); len(p) > room {
+		w.buf.Write(p[:room])
+		return room, io.ErrShortWrite
+	}
+	return w.buf.Write(p)
+}
+
+func Test_WriteToBuilder(t *testing.T) {
+	loopTest(t, "WriteToBuilder", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		r.Insert(stringSize.size/2, charSet.generator(stringSize.size))
+
+		var b strings.Builder
+		b.WriteString("prefix:")
+		r.WriteToBuilder(&b)
+		if b.String() != "prefix:"+r.String() {
+			t.Fatal("Incorrect content")
+		}
+	})
+}
+
+func Benchmark_Append(b *testing.B) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"AutoBalance", Options{}},
+		{"DisableAutoBalance", Options{DisableAutoBalance: true}},
+	}
+
+	s := generateASCIIString(10)
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := CreateRopeWithOptions("", tc.options)
+				for j := 0; j < 10000; j++ {
+					r.Insert(r.Length(), s)
+				}
+			}
+		})
+	}
+}
+
+func Benchmark_Append_Small(b *testing.B) {
+	tests := []struct {
+		name   string
+		append func(r *Rope, s string)
+	}{
+		{"Insert", func(r *Rope, s string) { r.Insert(r.Length(), s) }},
+		{"Append", func(r *Rope, s string) { r.Append(s) }},
+	}
+
+	s := generateASCIIString(10)
+	for _, tc := range tests {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := CreateRope("")
+				for j := 0; j < 100000; j++ {
+					tc.append(r, s)
+				}
+			}
+		})
+	}
+}
+
 func Benchmark_Alter(b *testing.B) {
 	tests := []struct {
 		name string
@@ -394,6 +2722,31 @@ func Benchmark_Insert_Small(b *testing.B) {
 	}
 }
 
+func Benchmark_Insert_Bulk(b *testing.B) {
+	init := generateASCIIString(10000)
+	x := generateASCIIString(1 << 20)
+
+	b.StopTimer()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r := CreateRope(init)
+
+		b.StartTimer()
+
+		err := r.Insert(5000, x)
+
+		b.StopTimer()
+
+		if err != nil {
+			b.Fatal("Error during tests.")
+		}
+		if i == 0 {
+			assertLogarithmicDepth(b, r)
+		}
+	}
+}
+
 func Benchmark_Reader(b *testing.B) {
 	tests := []struct {
 		name string
@@ -411,6 +2764,23 @@ func Benchmark_Reader(b *testing.B) {
 	}
 }
 
+func Benchmark_WriteTo(b *testing.B) {
+	for _, size := range []int{100000, 200000} {
+		init := generateASCIIString(size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			r := CreateRope(init)
+			var buf bytes.Buffer
+			buf.Grow(size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				r.WriteTo(&buf)
+			}
+		})
+	}
+}
+
 func Benchmark_Remove_Small(b *testing.B) {
 	tests := []struct {
 		name string
@@ -430,6 +2800,17 @@ func Benchmark_Remove_Small(b *testing.B) {
 	}
 }
 
+// assertLogarithmicDepth fails if the rope's tree is deeper than twice the
+// depth of a perfectly balanced tree of its leaves, with a little slack.  The
+// bound is worked out here rather than taken from maxDepth, so that loosening
+// the balancing does not loosen the test with it.
+func assertLogarithmicDepth(tb testing.TB, r *Rope) {
+	leaves := float64(r.Length())/joinLength + 1
+	if limit, depth := int(2*math.Log2(leaves))+4, r.root.depth; depth > limit {
+		tb.Fatalf("Tree is too deep: expected at most %d, got %d", limit, depth)
+	}
+}
+
 func loopAlterTest(t *testing.T, name string, alters []alterSetIn, f alterTestFunc) {
 	charSets := []charSet{
 		{"ASCII", generateASCIIString},
@@ -0,0 +1,158 @@
+package rope
+
+import (
+	"bufio"
+	"strings"
+	"unicode/utf8"
+)
+
+// Contains reports whether substr occurs in the Rope, as found by Index
+func (r *Rope) Contains(substr string) bool {
+	return r.Index(substr) >= 0
+}
+
+// CountRune returns the number of occurrences of the rune in the Rope.
+// Newlines are counted from the tree's cached counts without visiting any
+// leaves.  Leaves are always divided on rune boundaries, so other runes are
+// counted leaf by leaf; for single-byte runes this is a plain byte count with
+// no UTF-8 decoding.
+func (r *Rope) CountRune(ru rune) int {
+	if ru == '\n' {
+		return r.root.newlines
+	}
+
+	sep := string(ru)
+	count := 0
+	r.root.walk(func(value string) bool {
+		count += strings.Count(value, sep)
+		return true
+	})
+	return count
+}
+
+// Index returns the rune offset of the first occurrence of substr in the
+// Rope, or -1 if there is none.  An empty substr is found at 0.  Each leaf is
+// searched in place, and a match which spans leaves is found by also
+// searching the join of the end of the content before a leaf, which is kept
+// for the purpose, with the start of the leaf.
+func (r *Rope) Index(substr string) int {
+	if substr == "" {
+		return 0
+	}
+
+	result := -1
+	offset := 0
+	var tail string
+	tailLength := 0
+	r.root.walk(func(value string) bool {
+		if tail != "" {
+			join := tail + value[:min(len(value), len(substr)-1)]
+			if i := strings.Index(join, substr); i >= 0 && i < len(tail) {
+				result = offset - tailLength + utf8.RuneCountInString(tail[:i])
+				return false
+			}
+		}
+
+		if i := strings.Index(value, substr); i >= 0 {
+			result = offset + utf8.RuneCountInString(value[:i])
+			return false
+		}
+
+		// Keep enough of the end of the content to begin a match, starting
+		// at a rune so that its runes can be counted
+		offset += utf8.RuneCountInString(value)
+		if keep := len(substr) - 1; len(value) >= keep {
+			tail = value[len(value)-keep:]
+		} else {
+			tail += value
+			tail = tail[max(len(tail)-keep, 0):]
+		}
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+		tailLength = utf8.RuneCountInString(tail)
+		return true
+	})
+	return result
+}
+
+// SplitRunePositions returns the rune offset of every occurrence of sep in
+// the Rope, in order, so that the fields between them can be taken along
+// with where they lie.  The leaves are read once; a single-byte separator is
+// found with a byte search, and only the runes between separators are
+// counted.  A sep which is not a valid rune, such as a negative one or a
+// surrogate half, never occurs, so nil is returned for it.
+func (r *Rope) SplitRunePositions(sep rune) []int {
+	if !utf8.ValidRune(sep) {
+		return nil
+	}
+
+	var positions []int
+	single := sep < utf8.RuneSelf
+	sepString := string(sep)
+	offset := 0
+	r.root.walk(func(value string) bool {
+		for {
+			var i int
+			if single {
+				i = strings.IndexByte(value, byte(sep))
+			} else {
+				i = strings.Index(value, sepString)
+			}
+			if i < 0 {
+				offset += utf8.RuneCountInString(value)
+				return true
+			}
+
+			offset += utf8.RuneCountInString(value[:i])
+			positions = append(positions, offset)
+			offset++
+			value = value[i+len(sepString):]
+		}
+	})
+	return positions
+}
+
+// Tokenize scans the bytes of the Rope with the split function, as a
+// bufio.Scanner would, and calls fn with each token until fn returns false.
+// The content is streamed from the leaves rather than copied into one
+// string, and tokens which span leaves are assembled in the scanner's buffer.
+// The token passed to fn is only valid until fn returns.
+func (r *Rope) Tokenize(split bufio.SplitFunc, fn func(tok []byte) bool) {
+	scanner := bufio.NewScanner(r.NewReader())
+	scanner.Buffer(nil, max(r.root.byteLength+1, bufio.MaxScanTokenSize))
+	scanner.Split(split)
+	for scanner.Scan() {
+		if !fn(scanner.Bytes()) {
+			return
+		}
+	}
+}
+
+// RuneWindows calls fn with every run of size consecutive runes in the Rope,
+// in order, sliding by one rune each time, until fn returns false.  Windows
+// are taken across leaf boundaries, and a Rope of fewer than size runes has
+// none.  The slice passed to fn is reused for later windows, so it must be
+// copied to be kept.
+func (r *Rope) RuneWindows(size int, fn func(window []rune) bool) {
+	if size < 1 {
+		return
+	}
+
+	// The window slides along a buffer twice its size; when it reaches the
+	// end, the runes it holds are moved back to the start, so each rune is
+	// copied at most once per size runes read.
+	buf := make([]rune, 0, 2*size)
+	r.root.walk(func(value string) bool {
+		for _, ru := range value {
+			if len(buf) == cap(buf) {
+				buf = buf[:copy(buf, buf[len(buf)-size+1:])]
+			}
+			buf = append(buf, ru)
+			if len(buf) >= size && !fn(buf[len(buf)-size:]) {
+				return false
+			}
+		}
+		return true
+	})
+}
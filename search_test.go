@@ -0,0 +1,219 @@
+package rope
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_CountRune(t *testing.T) {
+	loopTest(t, "CountRune", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := strings.Map(func(ru rune) rune {
+			if ru == 'a' {
+				return '\n'
+			}
+			return ru
+		}, charSet.generator(stringSize.size))
+		r := CreateRope(init)
+
+		for _, ru := range []rune{'\n', 'b', 'Z', '🐿', 'Ω', '😀'} {
+			expected := strings.Count(init, string(ru))
+			if count := r.CountRune(ru); count != expected {
+				t.Fatalf("Incorrect count of %q: expected %d, got %d", ru, expected, count)
+			}
+		}
+	})
+}
+
+func Test_SplitRunePositions(t *testing.T) {
+	loopTest(t, "SplitRunePositions", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		r.Insert(stringSize.size/3, strings.Repeat("b🐿", 300))
+		runes := []rune(r.String())
+
+		for _, sep := range []rune{'b', 'Z', '🐿', 'Ω'} {
+			var expected []int
+			for i, ru := range runes {
+				if ru == sep {
+					expected = append(expected, i)
+				}
+			}
+			if positions := r.SplitRunePositions(sep); !reflect.DeepEqual(positions, expected) {
+				t.Fatalf("Incorrect positions of %q: expected %v, got %v", sep, expected, positions)
+			}
+		}
+	})
+
+	if positions := CreateRope("").SplitRunePositions(','); positions != nil {
+		t.Fatalf("Expected no positions, got %v", positions)
+	}
+	if positions := CreateRope("\xff\xfe").SplitRunePositions(-1); positions != nil {
+		t.Fatalf("Expected no positions of an invalid rune, got %v", positions)
+	}
+	if positions := CreateRope("xဠyဠz").SplitRunePositions(-128); positions != nil {
+		t.Fatalf("Expected no positions of an invalid rune, got %v", positions)
+	}
+}
+
+func Test_Index(t *testing.T) {
+	// Build the tree by hand, as concat joins leaves this small
+	root := &node{left: newNode("foo"), right: &node{left: newNode("b🐿"), right: newNode("ar")}}
+	root.right.recount()
+	root.recount()
+	r := &Rope{root: root}
+
+	for _, tc := range []struct {
+		substr   string
+		expected int
+	}{
+		{"", 0},
+		{"foo", 0},
+		{"oba", -1},
+		{"ob🐿", 2},
+		{"🐿a", 4},
+		{"o", 1},
+		{"ar", 5},
+		{"foob🐿ar", 0},
+		{"foob🐿arx", -1},
+		{"x", -1},
+	} {
+		if index := r.Index(tc.substr); index != tc.expected {
+			t.Fatalf("Incorrect index of %q: expected %d, got %d", tc.substr, tc.expected, index)
+		}
+		if contains := r.Contains(tc.substr); contains != (tc.expected >= 0) {
+			t.Fatalf("Incorrect Contains for %q", tc.substr)
+		}
+	}
+
+	root = &node{left: newNode("foo"), right: newNode("bar")}
+	root.recount()
+	if index := (&Rope{root: root}).Index("oba"); index != 2 {
+		t.Fatalf("Incorrect index of %q across leaves: expected 2, got %d", "oba", index)
+	}
+}
+
+func Test_Index_Large(t *testing.T) {
+	loopTest(t, "Index", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRope(charSet.generator(stringSize.size))
+		r.Insert(stringSize.size/3, charSet.generator(stringSize.size*2))
+		content := r.String()
+		runes := []rune(content)
+
+		for _, boundary := range r.LeafBoundaries()[1:] {
+			// A needle taken from across the boundary, and one just after it
+			start := r.root.runeOffset(boundary)
+			for _, span := range [][2]int{{max(start-3, 0), min(start+4, len(runes))}, {start, min(start+2, len(runes))}} {
+				substr := string(runes[span[0]:span[1]])
+				expected := utf8.RuneCountInString(content[:strings.Index(content, substr)])
+				if index := r.Index(substr); index != expected {
+					t.Fatalf("Incorrect index of %q: expected %d, got %d", substr, expected, index)
+				}
+			}
+		}
+
+		if index := r.Index("not#present"); index != -1 {
+			t.Fatalf("Expected -1, got %d", index)
+		}
+	})
+}
+
+func Test_Tokenize(t *testing.T) {
+	loopTest(t, "Tokenize", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := strings.Map(func(ru rune) rune {
+			if ru == 'a' || ru == 'Ω' {
+				return ' '
+			}
+			return ru
+		}, charSet.generator(stringSize.size))
+		r := CreateRope(init)
+
+		expected := strings.Fields(init)
+		words := []string{}
+		r.Tokenize(bufio.ScanWords, func(tok []byte) bool {
+			words = append(words, string(tok))
+			return true
+		})
+		if !reflect.DeepEqual(words, expected) {
+			t.Fatalf("Incorrect tokens: expected %d, got %d", len(expected), len(words))
+		}
+	})
+}
+
+func Test_Tokenize_Stop(t *testing.T) {
+	r := CreateRope("one\ntwo\nthree\n")
+	lines := []string{}
+	r.Tokenize(bufio.ScanLines, func(tok []byte) bool {
+		lines = append(lines, string(tok))
+		return len(lines) < 2
+	})
+	if !reflect.DeepEqual(lines, []string{"one", "two"}) {
+		t.Fatalf("Incorrect lines: got %q", lines)
+	}
+}
+
+func Test_Tokenize_LongToken(t *testing.T) {
+	init := generateASCIIString(2 * bufio.MaxScanTokenSize)
+	r := CreateRope(init)
+	count := 0
+	r.Tokenize(bufio.ScanLines, func(tok []byte) bool {
+		if string(tok) != init {
+			t.Fatalf("Incorrect token of %d bytes", len(tok))
+		}
+		count++
+		return true
+	})
+	if count != 1 {
+		t.Fatalf("Expected 1 token, got %d", count)
+	}
+}
+
+func Test_RuneWindows(t *testing.T) {
+	loopTest(t, "RuneWindows", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		runes := []rune(charSet.generator(stringSize.size))
+		r := CreateRope(string(runes))
+
+		for _, size := range []int{1, 2, 7, 300, stringSize.size, stringSize.size + 1} {
+			count := 0
+			r.RuneWindows(size, func(window []rune) bool {
+				if !reflect.DeepEqual(window, runes[count:count+size]) {
+					t.Fatalf("Incorrect window %d of size %d", count, size)
+				}
+				count++
+				return true
+			})
+			if expected := max(0, len(runes)-size+1); count != expected {
+				t.Fatalf("Incorrect number of windows of size %d: expected %d, got %d", size, expected, count)
+			}
+		}
+	})
+}
+
+func Test_RuneWindows_Stop(t *testing.T) {
+	r := CreateRope("🐿abcdef")
+	var windows []string
+	r.RuneWindows(3, func(window []rune) bool {
+		windows = append(windows, string(window))
+		return len(windows) < 3
+	})
+	if !reflect.DeepEqual(windows, []string{"🐿ab", "abc", "bcd"}) {
+		t.Fatalf("Incorrect windows: got %q", windows)
+	}
+
+	r.RuneWindows(0, func(window []rune) bool {
+		t.Fatal("Window of size 0")
+		return false
+	})
+}
+
+func Benchmark_CountRune(b *testing.B) {
+	r := CreateRope(generateASCIIString(200000))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r.CountRune('a')
+	}
+}
@@ -0,0 +1,100 @@
+package rope
+
+import (
+	"fmt"
+)
+
+// transaction is the state of a Rope saved by Begin, for Rollback to restore
+type transaction struct {
+	root    Version
+	markers []markerState
+}
+
+// markerState is a Marker and its state as saved by Begin
+type markerState struct {
+	m        *Marker
+	position int
+	valid    bool
+}
+
+// Begin starts a transaction, after which edits of the Rope are tentative
+// until Commit keeps them or Rollback discards them all together.  The tree
+// is shared rather than copied, as by Root, so beginning a transaction is
+// constant time apart from saving the positions of the Markers, and the
+// edits within it copy only the nodes which they touch.  Transactions do not
+// nest; it is an error to call Begin while one is open.
+func (r *Rope) Begin() error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if r.transaction != nil {
+		return fmt.Errorf("transaction already begun")
+	}
+
+	markers := make([]markerState, len(r.markers))
+	for i, m := range r.markers {
+		markers[i] = markerState{m, m.position, m.valid}
+	}
+	r.transaction = &transaction{r.Root(), markers}
+	return nil
+}
+
+// Commit ends the open transaction, keeping its edits.  It is an error if no
+// transaction is open.
+func (r *Rope) Commit() error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.transaction == nil {
+		return fmt.Errorf("no transaction begun")
+	}
+
+	r.transaction = nil
+	return nil
+}
+
+// Rollback ends the open transaction, restoring the content of the Rope as
+// it was at Begin.  Markers are returned to their positions at Begin, and any
+// created since are invalidated and unregistered.  The revision is advanced
+// rather than restored, as the content differs from that of the revision
+// before.  It is an error if no transaction is open, and ErrFrozen is
+// returned if the Rope was frozen during it.
+func (r *Rope) Rollback() error {
+	if r == nil {
+		return fmt.Errorf("Nil pointer receiver")
+	}
+
+	if r.frozen {
+		return ErrFrozen
+	}
+
+	if r.transaction == nil {
+		return fmt.Errorf("no transaction begun")
+	}
+
+	saved := r.transaction
+	r.transaction = nil
+
+	for _, m := range r.markers {
+		m.valid = false
+	}
+	r.markers = r.markers[:0]
+	for _, state := range saved.markers {
+		state.m.position = state.position
+		state.m.valid = state.valid
+		r.markers = append(r.markers, state.m)
+	}
+
+	length := r.root.length
+	r.root = saved.root.root
+	r.spine = nil
+	r.revision.Add(1)
+	r.notify(0, length, r.root.length)
+	return nil
+}
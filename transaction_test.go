@@ -0,0 +1,92 @@
+package rope
+
+import (
+	"testing"
+)
+
+func Test_Transaction(t *testing.T) {
+	loopTest(t, "Transaction", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init)
+		m, _ := r.Mark(stringSize.size / 2)
+		gone, _ := r.Mark(10)
+
+		for _, commit := range []bool{true, false} {
+			before := r.String()
+			begun, _ := m.Position()
+			if err := r.Begin(); err != nil {
+				t.Fatal(err)
+			}
+			r.Insert(0, "🐿")
+			r.Remove(5, 15)
+			r.Append(charSet.generator(stringSize.size))
+			added, _ := r.Mark(1)
+			after := r.String()
+
+			if commit {
+				if err := r.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				if r.String() != after {
+					t.Fatal("Commit changed the content")
+				}
+				if _, valid := gone.Position(); valid {
+					t.Fatal("Marker in removed range was not invalidated")
+				}
+				continue
+			}
+
+			revision := r.Revision()
+			if err := r.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+			if r.String() != before {
+				t.Fatal("Rollback did not restore the content")
+			}
+			if r.Revision() <= revision {
+				t.Fatal("Rollback did not advance the revision")
+			}
+			if restored, valid := m.Position(); restored != begun || !valid {
+				t.Fatalf("Marker was not restored: got %d, %t", restored, valid)
+			}
+			if _, valid := added.Position(); valid {
+				t.Fatal("Marker created within the transaction is still valid")
+			}
+			if err := r.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	r := CreateRope("abc")
+	if r.Commit() == nil || r.Rollback() == nil {
+		t.Fatal("Expected error with no transaction")
+	}
+	r.Begin()
+	if r.Begin() == nil {
+		t.Fatal("Expected error for nested transaction")
+	}
+	r.Insert(0, "x")
+	r.Rollback()
+	if r.String() != "abc" {
+		t.Fatal("Rollback did not restore the content")
+	}
+	r.Insert(0, "y")
+	if r.String() != "yabc" {
+		t.Fatal("Edit after rollback failed")
+	}
+}
+
+func Test_Rollback_Frozen(t *testing.T) {
+	r := CreateRope("abc")
+	r.Begin()
+	r.Insert(0, "x")
+	r.Freeze()
+	revision := r.Revision()
+	if err := r.Rollback(); err != ErrFrozen {
+		t.Fatalf("Expected ErrFrozen, got %v", err)
+	}
+	if r.String() != "xabc" || r.Revision() != revision {
+		t.Fatal("Rollback changed the frozen rope")
+	}
+}
@@ -0,0 +1,334 @@
+package rope
+
+import (
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wide holds the runes with an East Asian Width of Wide or Fullwidth, which
+// occupy two columns in a terminal
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115F, 1},
+		{0x231A, 0x231B, 1},
+		{0x2329, 0x232A, 1},
+		{0x23E9, 0x23EC, 1},
+		{0x23F0, 0x23F0, 1},
+		{0x23F3, 0x23F3, 1},
+		{0x25FD, 0x25FE, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267F, 0x267F, 1},
+		{0x2693, 0x2693, 1},
+		{0x26A1, 0x26A1, 1},
+		{0x26AA, 0x26AB, 1},
+		{0x26BD, 0x26BE, 1},
+		{0x26C4, 0x26C5, 1},
+		{0x26CE, 0x26CE, 1},
+		{0x26D4, 0x26D4, 1},
+		{0x26EA, 0x26EA, 1},
+		{0x26F2, 0x26F3, 1},
+		{0x26F5, 0x26F5, 1},
+		{0x26FA, 0x26FA, 1},
+		{0x26FD, 0x26FD, 1},
+		{0x2705, 0x2705, 1},
+		{0x270A, 0x270B, 1},
+		{0x2728, 0x2728, 1},
+		{0x274C, 0x274C, 1},
+		{0x274E, 0x274E, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27B0, 0x27B0, 1},
+		{0x27BF, 0x27BF, 1},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B50, 1},
+		{0x2B55, 0x2B55, 1},
+		{0x2E80, 0x303E, 1},
+		{0x3041, 0x33FF, 1},
+		{0x3400, 0x4DBF, 1},
+		{0x4E00, 0x9FFF, 1},
+		{0xA000, 0xA4CF, 1},
+		{0xA960, 0xA97F, 1},
+		{0xAC00, 0xD7A3, 1},
+		{0xF900, 0xFAFF, 1},
+		{0xFE10, 0xFE19, 1},
+		{0xFE30, 0xFE6F, 1},
+		{0xFF00, 0xFF60, 1},
+		{0xFFE0, 0xFFE6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16FE0, 0x16FE4, 1},
+		{0x17000, 0x18AFF, 1},
+		{0x1B000, 0x1B2FF, 1},
+		{0x1F004, 0x1F004, 1},
+		{0x1F0CF, 0x1F0CF, 1},
+		{0x1F18E, 0x1F18E, 1},
+		{0x1F191, 0x1F19A, 1},
+		{0x1F200, 0x1F202, 1},
+		{0x1F210, 0x1F23B, 1},
+		{0x1F240, 0x1F248, 1},
+		{0x1F250, 0x1F251, 1},
+		{0x1F260, 0x1F265, 1},
+		{0x1F300, 0x1F320, 1},
+		{0x1F32D, 0x1F335, 1},
+		{0x1F337, 0x1F37C, 1},
+		{0x1F37E, 0x1F393, 1},
+		{0x1F3A0, 0x1F3CA, 1},
+		{0x1F3CF, 0x1F3D3, 1},
+		{0x1F3E0, 0x1F3F0, 1},
+		{0x1F3F4, 0x1F3F4, 1},
+		{0x1F3F8, 0x1F43E, 1},
+		{0x1F440, 0x1F440, 1},
+		{0x1F442, 0x1F4FC, 1},
+		{0x1F4FF, 0x1F53D, 1},
+		{0x1F54B, 0x1F54E, 1},
+		{0x1F550, 0x1F567, 1},
+		{0x1F57A, 0x1F57A, 1},
+		{0x1F595, 0x1F596, 1},
+		{0x1F5A4, 0x1F5A4, 1},
+		{0x1F5FB, 0x1F64F, 1},
+		{0x1F680, 0x1F6C5, 1},
+		{0x1F6CC, 0x1F6CC, 1},
+		{0x1F6D0, 0x1F6D2, 1},
+		{0x1F6D5, 0x1F6D7, 1},
+		{0x1F6EB, 0x1F6EC, 1},
+		{0x1F6F4, 0x1F6FC, 1},
+		{0x1F7E0, 0x1F7EB, 1},
+		{0x1F90C, 0x1F93A, 1},
+		{0x1F93C, 0x1F945, 1},
+		{0x1F947, 0x1F9FF, 1},
+		{0x1FA70, 0x1FAFF, 1},
+		{0x20000, 0x2FFFD, 1},
+		{0x30000, 0x3FFFD, 1},
+	},
+}
+
+// emoji holds the blocks of pictographic symbols most used as emoji
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x2600, 0x27BF, 1},
+		{0x2B00, 0x2BFF, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1F000, 0x1FAFF, 1},
+	},
+}
+
+// scripts are the categories reported by DominantScript, in the order in
+// which they are tried
+var scripts = []struct {
+	name   string
+	tables []*unicode.RangeTable
+}{
+	{"Emoji", []*unicode.RangeTable{emoji}},
+	{"Latin", []*unicode.RangeTable{unicode.Latin}},
+	{"CJK", []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo}},
+	{"Greek", []*unicode.RangeTable{unicode.Greek}},
+	{"Cyrillic", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"Arabic", []*unicode.RangeTable{unicode.Arabic}},
+	{"Hebrew", []*unicode.RangeTable{unicode.Hebrew}},
+	{"Devanagari", []*unicode.RangeTable{unicode.Devanagari}},
+	{"Thai", []*unicode.RangeTable{unicode.Thai}},
+}
+
+const (
+	// scriptSamples is the number of evenly spaced stretches of a large Rope
+	// examined by DominantScript, and scriptSampleLength the runes in each
+	scriptSamples      = 16
+	scriptSampleLength = 256
+)
+
+// DisplayWidth returns the number of terminal columns needed to display the
+// Rope.  Wide and fullwidth runes count as two columns, combining marks,
+// format characters and control characters as none, and all other runes as
+// one.  Newlines are not treated specially, so for a document of several
+// lines this is the sum of the widths of its lines.
+func (r *Rope) DisplayWidth() int {
+	width := 0
+	r.root.walk(func(value string) bool {
+		width += stringWidth(value)
+		return true
+	})
+	return width
+}
+
+// DominantScript reports the category of most of the text in the Rope:
+// "Latin", "CJK", "Emoji", "Greek", "Cyrillic", "Arabic", "Hebrew",
+// "Devanagari" or "Thai".  Runes which belong to none of these, such as
+// spaces, digits and punctuation, are not counted.  If no category holds more
+// than half of the counted runes, the result is "Mixed", and if there are no
+// counted runes at all, it is "".  A Rope of more than 4096 runes is sampled
+// rather than read in full: 16 stretches of 256 runes are taken at even
+// intervals from its start to its end.
+func (r *Rope) DominantScript() string {
+	counts := make([]int, len(scripts))
+	total := 0
+	count := func(value string) bool {
+		for _, ru := range value {
+			if ru < utf8.RuneSelf && !('a' <= ru && ru <= 'z' || 'A' <= ru && ru <= 'Z') {
+				continue
+			}
+			for i, script := range scripts {
+				if unicode.In(ru, script.tables...) {
+					counts[i]++
+					total++
+					break
+				}
+			}
+		}
+		return true
+	}
+
+	length := r.root.length
+	if length <= scriptSamples*scriptSampleLength {
+		r.root.walk(count)
+	} else {
+		for i := 0; i < scriptSamples; i++ {
+			start := (length - scriptSampleLength) * i / (scriptSamples - 1)
+			r.root.walkRange(start, start+scriptSampleLength, count)
+		}
+	}
+
+	if total == 0 {
+		return ""
+	}
+	for i, script := range scripts {
+		if 2*counts[i] > total {
+			return script.name
+		}
+	}
+	return "Mixed"
+}
+
+// FirstInvalidUTF8 returns the byte offset from the start of the Rope of the
+// first byte which does not begin a valid UTF-8 sequence, or -1 if the whole
+// Rope is valid UTF-8.  A sequence which is divided between leaves is decoded
+// as a whole, and a sequence which is cut short by the end of the Rope is
+// reported as invalid.
+func (r *Rope) FirstInvalidUTF8() int {
+	var pending [utf8.UTFMax]byte
+	pendingLength := 0
+	pendingStart := 0
+	offset := 0
+	result := -1
+
+	r.root.walk(func(value string) bool {
+		i := 0
+		if pendingLength > 0 {
+			// Complete the sequence begun in the previous leaves
+			n := copy(pending[pendingLength:], value)
+			seq := pending[:pendingLength+n]
+			if !utf8.FullRune(seq) {
+				pendingLength += n
+				offset += len(value)
+				return true
+			}
+			ru, size := utf8.DecodeRune(seq)
+			if ru == utf8.RuneError && size == 1 {
+				result = pendingStart
+				return false
+			}
+			i = size - pendingLength
+			pendingLength = 0
+		}
+
+		for i < len(value) {
+			if value[i] < utf8.RuneSelf {
+				i++
+				continue
+			}
+			if !utf8.FullRuneInString(value[i:]) {
+				pendingLength = copy(pending[:], value[i:])
+				pendingStart = offset + i
+				break
+			}
+			ru, size := utf8.DecodeRuneInString(value[i:])
+			if ru == utf8.RuneError && size == 1 {
+				result = offset + i
+				return false
+			}
+			i += size
+		}
+
+		offset += len(value)
+		return true
+	})
+
+	if result == -1 && pendingLength > 0 {
+		result = pendingStart
+	}
+	return result
+}
+
+// LineDisplayWidth returns the number of terminal columns needed to display
+// the 0-based line, measured as by DisplayWidth and excluding the newline
+// which terminates it.  A line which does not exist has no width.
+func (r *Rope) LineDisplayWidth(line int) int {
+	if line < 0 || line > r.root.newlines {
+		return 0
+	}
+
+	width := 0
+	r.root.walkRange(r.root.lineStart(line), r.root.lineEnd(line), func(value string) bool {
+		width += stringWidth(value)
+		return true
+	})
+	return width
+}
+
+// Normalizer transforms a stream of UTF-8 text into a Unicode normalization
+// form.  The norm.Form of golang.org/x/text/unicode/norm satisfies it, so
+// norm.NFC and the other forms may be passed as they are; the package takes
+// the interface rather than norm.Form as it does not depend on x/text.
+type Normalizer interface {
+	Reader(r io.Reader) io.Reader
+}
+
+// Normalize returns a new Rope holding the content of this one transformed by
+// the normalizer, so that text which looks the same but is made of different
+// code points compares equal.  Any norm.Form of golang.org/x/text/unicode/norm,
+// such as norm.NFC or norm.NFD, satisfies Normalizer and may be passed as it
+// is.  The content is streamed through the normalizer and the new tree is
+// built with its own counts, since normalizing changes both the rune and byte
+// lengths.  The new Rope has the same options as this one, and this one is
+// unchanged.  If the normalizer fails, its error is returned rather than a
+// Rope of what it read before failing.
+func (r *Rope) Normalize(form Normalizer) (*Rope, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Nil pointer receiver")
+	}
+
+	var b Builder
+	if _, err := io.Copy(&b, form.Reader(r.NewReader())); err != nil {
+		return nil, err
+	}
+	result := b.Rope()
+	result.options = r.options
+	return result, nil
+}
+
+// runeWidth returns the number of terminal columns occupied by the rune
+func runeWidth(ru rune) int {
+	switch {
+	case ru < 0x20 || ru >= 0x7F && ru < 0xA0:
+		return 0
+	case ru < 0x7F:
+		return 1
+	case unicode.In(ru, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case unicode.Is(wide, ru):
+		return 2
+	}
+	return 1
+}
+
+func stringWidth(s string) int {
+	width := 0
+	for _, ru := range s {
+		width += runeWidth(ru)
+	}
+	return width
+}
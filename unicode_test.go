@@ -0,0 +1,224 @@
+package rope
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func Test_FirstInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		expected int
+	}{
+		{"empty", "", -1},
+		{"ascii", "abc", -1},
+		{"unicode", "🐿a🐿", -1},
+		{"replacement-char", "a�b", -1},
+		{"invalid-start", "\xFFabc", 0},
+		{"invalid-middle", "ab\x80c", 2},
+		{"bad-continuation", "a\xE2\x28\xA1", 1},
+		{"truncated-end", "abc\xF0\x9F\x90", 3},
+		{"after-unicode", "🐿🐿\xC0", 8},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if offset := CreateRope(tc.init).FirstInvalidUTF8(); offset != tc.expected {
+				t.Fatalf("Incorrect offset: expected %d, got %d", tc.expected, offset)
+			}
+		})
+	}
+}
+
+func Test_FirstInvalidUTF8_Across_Leaves(t *testing.T) {
+	squirrel := "🐿"
+	tests := []struct {
+		name     string
+		leaves   []string
+		expected int
+	}{
+		{"split-2-2", []string{"a" + squirrel[:2], squirrel[2:] + "b"}, -1},
+		{"split-1-1-2", []string{"a" + squirrel[:1], squirrel[1:2], squirrel[2:] + "b"}, -1},
+		{"split-3-1", []string{squirrel[:3], squirrel[3:]}, -1},
+		{"split-invalid", []string{"ab" + squirrel[:2], "b"}, 2},
+		{"split-truncated", []string{"ab", squirrel[:2]}, 2},
+		{"split-truncated-tiny", []string{"ab", squirrel[:1], squirrel[1:2]}, 2},
+		{"invalid-later-leaf", []string{squirrel, "ab", "c\xFF"}, 7},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			leaves := make([]*node, len(tc.leaves))
+			for i, leaf := range tc.leaves {
+				leaves[i] = newNode(leaf)
+			}
+			r := &Rope{root: merge(nil, leaves)}
+			if offset := r.FirstInvalidUTF8(); offset != tc.expected {
+				t.Fatalf("Incorrect offset: expected %d, got %d", tc.expected, offset)
+			}
+		})
+	}
+}
+
+func Test_FirstInvalidUTF8_Large(t *testing.T) {
+	loopTest(t, "FirstInvalidUTF8-Large", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		r := CreateRope(init + "\xFF" + init)
+		if offset := r.FirstInvalidUTF8(); offset != len(init) {
+			t.Fatalf("Incorrect offset: expected %d, got %d", len(init), offset)
+		}
+	})
+}
+
+func Test_DisplayWidth(t *testing.T) {
+	tests := []struct {
+		init     string
+		expected int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"™¥§©®¼¾", 7},
+		{"ΔΦΩθλϢ", 6},
+		{"🐈🐑🍩☕🍷🍺🔪🚇🚲🕐📷🔬", 24},
+		{"🐿", 1},
+		{"日本語", 6},
+		{"ｆｕｌｌ", 8},
+		{"e\u0301", 1},
+		{"a\u200db", 2},
+		{"a\tb\n", 2},
+	}
+
+	for _, tc := range tests {
+		if width := CreateRope(tc.init).DisplayWidth(); width != tc.expected {
+			t.Fatalf("Incorrect width of %q: expected %d, got %d", tc.init, tc.expected, width)
+		}
+	}
+}
+
+func Test_DisplayWidth_Large(t *testing.T) {
+	loopTest(t, "DisplayWidth-Large", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size)
+		expected := 0
+		for _, ru := range init {
+			if strings.ContainsRune("🐈🐑🍩☕🍷🍺🔪🚇🚲🕐📷🔬", ru) {
+				expected += 2
+			} else {
+				expected++
+			}
+		}
+
+		if width := CreateRope(init).DisplayWidth(); width != expected {
+			t.Fatalf("Incorrect width: expected %d, got %d", expected, width)
+		}
+	})
+}
+
+func Test_LineDisplayWidth(t *testing.T) {
+	long := strings.Repeat("🐈a", 400)
+	r := CreateRope("abc\n🐈🐈\n\n" + long + "\n日本")
+	expected := []int{3, 4, 0, 1200, 4}
+
+	for line, width := range expected {
+		if actual := r.LineDisplayWidth(line); actual != width {
+			t.Fatalf("Incorrect width of line %d: expected %d, got %d", line, width, actual)
+		}
+	}
+	if width := r.LineDisplayWidth(len(expected)); width != 0 {
+		t.Fatalf("Nonexistent line has width %d", width)
+	}
+}
+
+// testForm is a stand-in for a norm.Form which composes or decomposes a few
+// Latin letters with acute accents, and hands its output back a byte at a
+// time so that runes are divided between reads
+type testForm bool
+
+const (
+	testNFC testForm = true
+	testNFD testForm = false
+)
+
+func (f testForm) Reader(r io.Reader) io.Reader {
+	pairs := []string{"e\u0301", "é", "a\u0301", "á", "E\u0301", "É"}
+	if !f {
+		for i := 0; i < len(pairs); i += 2 {
+			pairs[i], pairs[i+1] = pairs[i+1], pairs[i]
+		}
+	}
+	b, _ := io.ReadAll(r)
+	return iotest.OneByteReader(strings.NewReader(strings.NewReplacer(pairs...).Replace(string(b))))
+}
+
+func Test_Normalize(t *testing.T) {
+	composed := strings.Repeat("Café 🐈 Éa á\n", 200)
+	decomposed := strings.Repeat("Cafe\u0301 🐈 E\u0301a a\u0301\n", 200)
+
+	r := CreateRopeWithOptions(decomposed, Options{MaxBytes: 1 << 20})
+	nfc, err := r.Normalize(testNFC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nfc.String() != composed {
+		t.Fatal("Incorrect composed content")
+	}
+	if nfc.Length() != len([]rune(composed)) || nfc.ByteLength() != len(composed) {
+		t.Fatalf("Incorrect lengths: got %d runes and %d bytes", nfc.Length(), nfc.ByteLength())
+	}
+	if err := nfc.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if nfc.options != r.options {
+		t.Fatal("Options were not kept")
+	}
+	if r.String() != decomposed {
+		t.Fatal("Original rope was changed")
+	}
+
+	if nfd, err := nfc.Normalize(testNFD); err != nil || nfd.String() != decomposed || nfd.Length() != r.Length() {
+		t.Fatal("Incorrect decomposed content")
+	}
+}
+
+// failingForm is a Normalizer which fails after passing some of its input
+type failingForm struct{}
+
+func (failingForm) Reader(r io.Reader) io.Reader {
+	return io.MultiReader(io.LimitReader(r, 10), iotest.ErrReader(iotest.ErrTimeout))
+}
+
+func Test_Normalize_Error(t *testing.T) {
+	r := CreateRope(strings.Repeat("Café\n", 100))
+	if result, err := r.Normalize(failingForm{}); err != iotest.ErrTimeout || result != nil {
+		t.Fatalf("Expected the normalizer's error, got %v", err)
+	}
+}
+
+func Test_DominantScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		init     string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"punctuation", "123, 456!\n", ""},
+		{"latin", "The quick brown fox, 1234 🐈.", "Latin"},
+		{"cjk", "日本語のテキスト、漢字とかな。 abc", "CJK"},
+		{"emoji", "🐈🐑🐿🍩 ☕ ok", "Emoji"},
+		{"cyrillic", "Привет, мир", "Cyrillic"},
+		{"mixed", "hello 日本語 🐈🐑", "Mixed"},
+		{"large-latin", strings.Repeat(generateASCIIString(1000)+" 🐈 ", 100), "Latin"},
+		{"large-emoji", strings.Repeat("🐈 🐑 🍩 ok\n", 2000), "Emoji"},
+		{"large-cjk", strings.Repeat("漢字かな", 5000) + strings.Repeat("a", 100), "CJK"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if script := CreateRope(tc.init).DominantScript(); script != tc.expected {
+				t.Fatalf("Incorrect script: expected %q, got %q", tc.expected, script)
+			}
+		})
+	}
+}
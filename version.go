@@ -0,0 +1,94 @@
+package rope
+
+// Version is an immutable handle to the content of a Rope at one point in
+// time, as returned by Root.  The zero Version is an empty document.
+//
+// A Version holds the tree of the Rope as it was, sharing every node with
+// the Rope and with other Versions until one of them is edited.  Edits copy
+// only the nodes along the path they change, so a history of Versions costs
+// little more than the edits made between them.  However, the content of a
+// Version is held in memory for as long as the Version is reachable, even
+// after the Rope has moved on; a history must drop Versions it no longer
+// needs for them to be collected.
+//
+// The edit methods of a Version return a new Version rather than changing
+// it, so it may also be used as a persistent document in its own right.  A
+// Version may be read and edited from any number of goroutines at once.
+type Version struct {
+	root    *node
+	options Options
+}
+
+// FromVersion creates a Rope holding the content of the Version.  The Rope
+// shares its structure with the Version, which is unaffected by its edits.
+func FromVersion(v Version) *Rope {
+	if v.root == nil {
+		return CreateRopeWithOptions("", v.options)
+	}
+
+	return &Rope{root: v.root, options: v.options}
+}
+
+// Root returns a Version holding the current content of the Rope.  The tree
+// is shared rather than copied, so this is constant time.
+func (r *Rope) Root() Version {
+	r.root.shared.Store(true)
+	return Version{r.root, r.options}
+}
+
+// Snapshot returns a new Rope holding the current content of this one, for
+// an undo history.  Like a Version, the snapshot shares the whole tree rather
+// than copying it, and an edit of either Rope afterwards copies only the
+// nodes along the path it changes, so the other is unaffected.
+func (r *Rope) Snapshot() *Rope {
+	return FromVersion(r.Root())
+}
+
+// Alter returns a new Version in which the runes between start and end are
+// replaced with the value, as Rope.Alter does.  The new Version shares every
+// node with this one but those along the edited path, and this one is
+// unchanged.  If the edit fails, this Version is returned with the error.
+func (v Version) Alter(start, end int, value string) (Version, error) {
+	return v.edit(func(r *Rope) error { return r.Alter(start, end, value) })
+}
+
+// Insert returns a new Version with the value inserted at the rune offset,
+// as Rope.Insert does.  As with Alter, this Version is unchanged.
+func (v Version) Insert(position int, value string) (Version, error) {
+	return v.edit(func(r *Rope) error { return r.Insert(position, value) })
+}
+
+// Length returns the number of runes in the Version
+func (v Version) Length() int {
+	if v.root == nil {
+		return 0
+	}
+	return v.root.length
+}
+
+// Remove returns a new Version without the runes between start and end, as
+// Rope.Remove does.  As with Alter, this Version is unchanged.
+func (v Version) Remove(start, end int) (Version, error) {
+	return v.edit(func(r *Rope) error { return r.Remove(start, end) })
+}
+
+// String returns the content of the Version
+func (v Version) String() string {
+	return FromVersion(v).String()
+}
+
+// Substring returns the runes of the Version between start and end, as
+// Rope.Substring does
+func (v Version) Substring(start, end int) (string, error) {
+	return FromVersion(v).Substring(start, end)
+}
+
+// edit returns the Version made by applying the edit to a Rope holding this
+// one, or this Version and the error if the edit fails
+func (v Version) edit(edit func(r *Rope) error) (Version, error) {
+	r := FromVersion(v)
+	if err := edit(r); err != nil {
+		return v, err
+	}
+	return r.Root(), nil
+}
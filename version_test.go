@@ -0,0 +1,198 @@
+package rope
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func Test_Version(t *testing.T) {
+	loopTest(t, "Version", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		r := CreateRopeWithOptions(charSet.generator(stringSize.size), Options{MaxBytes: 1 << 20})
+
+		var history []Version
+		var contents []string
+		for i := 0; i < 20; i++ {
+			history = append(history, r.Root())
+			contents = append(contents, r.String())
+			switch i % 3 {
+			case 0:
+				r.Insert(i*7%r.Length(), charSet.generator(50))
+			case 1:
+				r.Remove(i, i+30)
+			case 2:
+				r.Append(charSet.generator(5))
+			}
+		}
+
+		for i, v := range history {
+			restored := FromVersion(v)
+			if restored.String() != contents[i] {
+				t.Fatalf("Version %d does not hold its content", i)
+			}
+			if restored.options != r.options {
+				t.Fatalf("Version %d did not keep the options", i)
+			}
+
+			restored.Insert(0, "x")
+			if FromVersion(v).String() != contents[i] {
+				t.Fatalf("Editing a rope from version %d changed the version", i)
+			}
+		}
+	})
+
+	if r := FromVersion(Version{}); !r.IsEmpty() {
+		t.Fatal("Zero version is not empty")
+	}
+}
+
+func Test_Snapshot(t *testing.T) {
+	loopTest(t, "Snapshot", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size * 10)
+		r := CreateRope(init)
+		snapshot := r.Snapshot()
+
+		r.Insert(stringSize.size, "inserted")
+		r.Remove(0, 10)
+		if snapshot.String() != init {
+			t.Fatal("Snapshot changed with the original")
+		}
+		expected := r.String()
+
+		snapshot.Remove(5, stringSize.size*5)
+		if r.String() != expected {
+			t.Fatal("Original changed with the snapshot")
+		}
+		if err := snapshot.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Test_Snapshot_Allocations(t *testing.T) {
+	r := CreateRope(generateASCIIString(1 << 20))
+	position := 1 << 19
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Snapshot()
+		r.Insert(position, "x")
+	})
+
+	// The path to the leaf is copied, with a new string for the leaf
+	if limit := float64(2*r.root.depth + 8); allocs > limit {
+		t.Fatalf("Snapshot and insert made %g allocations; expected at most %g for depth %d", allocs, limit, r.root.depth)
+	}
+}
+
+func Test_Snapshot_Frozen(t *testing.T) {
+	r := CreateRope("abc")
+	r.Freeze()
+	snapshot := r.Snapshot()
+	if snapshot.IsFrozen() {
+		t.Fatal("Snapshot of a frozen rope is frozen")
+	}
+	if err := snapshot.Insert(1, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "abc" || snapshot.String() != "axbc" {
+		t.Fatal("Edit of snapshot changed the frozen rope")
+	}
+}
+
+func Test_Version_Edits(t *testing.T) {
+	loopTest(t, "Version-Edits", func(t *testing.T, charSet charSet, stringSize stringSize) {
+		init := charSet.generator(stringSize.size * 5)
+		runes := []rune(init)
+		v := CreateRope(init).Root()
+
+		inserted, err := v.Insert(stringSize.size, "🐿")
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed, err := inserted.Remove(0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		altered, err := removed.Alter(5, 20, "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{
+			init,
+			string(runes[:stringSize.size]) + "🐿" + string(runes[stringSize.size:]),
+			string(runes[10:stringSize.size]) + "🐿" + string(runes[stringSize.size:]),
+			string(runes[10:15]) + "x" + string(runes[30:stringSize.size]) + "🐿" + string(runes[stringSize.size:]),
+		}
+		for i, version := range []Version{v, inserted, removed, altered} {
+			if version.String() != expected[i] || version.Length() != len([]rune(expected[i])) {
+				t.Fatalf("Version %d does not hold its content", i)
+			}
+		}
+	})
+
+	var v Version
+	if v.Length() != 0 || v.String() != "" {
+		t.Fatal("Zero version is not empty")
+	}
+	if _, err := v.Remove(0, 1); err != ErrIndexOutOfRange {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+	if _, err := v.Alter(1, 0, "x"); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Fatalf("Expected ErrIndexOutOfRange, got %v", err)
+	}
+}
+
+func Test_Version_ConcurrentReads(t *testing.T) {
+	init := generateASCIIString(100000)
+	v := CreateRope(init).Root()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if v.String() != init {
+					t.Error("Version changed while read")
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		if _, err := v.Insert(i*400, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+func Test_Version_ConcurrentEdits(t *testing.T) {
+	init := generateASCIIString(100000)
+	v := CreateRope(init).Root()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			edited := v
+			for j := 0; j < 100; j++ {
+				var err error
+				if edited, err = edited.Insert(j*900, "x"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			if edited.Length() != len(init)+100 {
+				t.Error("Incorrect length after edits")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v.String() != init {
+		t.Fatal("Version changed by concurrent edits")
+	}
+}